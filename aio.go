@@ -1,9 +1,12 @@
 package log
 
 import (
+	"errors"
 	"io"
 	"runtime"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type aio struct {
@@ -15,23 +18,44 @@ type aio struct {
 // AIO implements buffering asynchronous Writer for an io.Writer object.
 // Which can reduce the latency spike of api occurrence by disk/system latency.
 // If an error occurs writing to a Writer, no more data will be
-// accepted and all subsequent writes, and Flush, will return the error.
+// accepted and all subsequent writes, and Flush, will return the error,
+// except the failures of some of the writers of a TeeWriter.
 // After all data has been written, the client should call the
 // Flush method to guarantee all data has been forwarded to
-// the underlying io.Writer.
+// the underlying io.Writer. The buffered data is also flushed periodically
+// by AIOFlushInterval.
 type AIO struct {
+	mu      sync.Mutex // guards the buffer against the auto-flush
 	fault   *atomic.Value
 	buf     []byte
 	n, size int
 	w       io.Writer
 	ch      chan *aio
 	shared  chan []byte
+	done    chan struct{}
+	every   time.Duration // the interval of the auto-flush
+	stop    chan struct{} // stops the auto-flush, see AIOFlushInterval
+	closed  bool
 }
 
+// AIOOption configures the AIO when it is created.
+type AIOOption func(*AIO)
+
+// AIOFlushInterval flushes the buffered data every interval if there is any,
+// so that the data of a low-traffic AIO reaches the underlying io.Writer in
+// the bounded latency. The AIO must be closed to stop the flushing, it is
+// not collected by the finalizer.
+func AIOFlushInterval(interval time.Duration) AIOOption {
+	return func(a *AIO) { a.every = interval }
+}
+
+// ErrAIOClosed is returned by the writes of the closed AIO.
+var ErrAIOClosed = errors.New("log: aio is closed")
+
 // NewAIO returns a new Writer whose buffer has at least the specified
 // size. If the argument io.Writer is already a Writer with large enough
 // size, it returns the underlying Writer.
-func NewAIO(w io.Writer, size int) *AIO {
+func NewAIO(w io.Writer, size int, opts ...AIOOption) *AIO {
 	a := &AIO{
 		fault:  &atomic.Value{},
 		buf:    make([]byte, size),
@@ -39,19 +63,50 @@ func NewAIO(w io.Writer, size int) *AIO {
 		w:      w,
 		ch:     make(chan *aio, 128),
 		shared: make(chan []byte, 128),
+		done:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	go loop(a.ch, a.shared, a.fault, a.done)
+	if a.every > 0 {
+		a.stop = make(chan struct{})
+		go a.autoflush(a.every)
+	} else {
+		runtime.SetFinalizer(a, func(a *AIO) { close(a.ch) })
 	}
-	go loop(a.ch, a.shared, a.fault)
-	runtime.SetFinalizer(a, func(a *AIO) { close(a.ch) })
 	return a
 }
 
-func loop(reqch chan *aio, shared chan []byte, fault *atomic.Value) {
+func (a *AIO) autoflush(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.mu.Lock()
+			if a.n != 0 && a.haserror() == nil {
+				a.flush()
+			}
+			a.mu.Unlock()
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+func loop(reqch chan *aio, shared chan []byte, fault *atomic.Value, done chan struct{}) {
+	defer close(done)
 	for req := range reqch {
 		if len(req.b) != 0 && req.w != nil {
 			n, err := req.w.Write(req.b)
 			if n < len(req.b) && err == nil {
 				err = io.ErrShortWrite
 			}
+			var te *TeeError
+			if errors.As(err, &te) && n == len(req.b) {
+				err = nil // written by some of the writers of the TeeWriter
+			}
 			if err == nil {
 				select {
 				case shared <- req.b:
@@ -70,6 +125,11 @@ func loop(reqch chan *aio, shared chan []byte, fault *atomic.Value) {
 // Reset discards any unflushed buffered data, clears any error, and
 // resets b to write its output to w.
 func (a *AIO) Reset(w io.Writer) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return
+	}
 	a.fault.Store(struct{ error }{nil})
 	a.n = 0
 	a.w = w
@@ -91,6 +151,13 @@ func (a *AIO) free() []byte {
 
 // Flush writes any buffered data to the underlying io.Writer.
 func (a *AIO) Flush() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.flushwait()
+}
+
+// flushwait writes the buffered data and waits for the writing.
+func (a *AIO) flushwait() error {
 	if e := a.haserror(); e != nil {
 		return e
 	}
@@ -106,6 +173,27 @@ func (a *AIO) Flush() error {
 	return a.haserror()
 }
 
+// Close flushes the buffered data and stops the background goroutine, the
+// subsequent writes return ErrAIOClosed.
+func (a *AIO) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return ErrAIOClosed
+	}
+	err := a.flushwait()
+	a.closed = true
+	a.fault.Store(struct{ error }{ErrAIOClosed})
+	runtime.SetFinalizer(a, nil)
+	if a.stop != nil {
+		close(a.stop)
+	}
+	close(a.ch)
+	a.mu.Unlock()
+	<-a.done
+	return err
+}
+
 func (a *AIO) flush() {
 	aio := &aio{
 		w: a.w,
@@ -117,17 +205,29 @@ func (a *AIO) flush() {
 }
 
 // Available returns how many bytes are unused in the buffer.
-func (a *AIO) Available() int { return len(a.buf) - a.n }
+func (a *AIO) Available() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.available()
+}
+
+func (a *AIO) available() int { return len(a.buf) - a.n }
 
 // Buffered returns the number of bytes that have been written into the current buffer.
-func (a *AIO) Buffered() int { return a.n }
+func (a *AIO) Buffered() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.n
+}
 
 // Write writes the contents of p into the buffer.
 // It returns the number of bytes written.
 // If nn < len(p), it also returns an error explaining
 // why the write is short.
 func (a *AIO) Write(p []byte) (nn int, err error) {
-	for len(p) > a.Available() && a.haserror() == nil {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for len(p) > a.available() && a.haserror() == nil {
 		n := copy(a.buf[a.n:], p)
 		a.n += n
 		a.flush()
@@ -5,7 +5,9 @@ import (
 	"io"
 	"io/ioutil"
 	"runtime"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -66,3 +68,71 @@ type faultbuf struct{}
 func (b *faultbuf) Write(p []byte) (int, error) {
 	return 0, io.ErrClosedPipe
 }
+
+func TestAIOClose(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		w0     = bytes.NewBuffer(nil)
+		before = runtime.NumGoroutine()
+		aios   []*AIO
+	)
+
+	for i := 0; i < 10; i++ {
+		aios = append(aios, NewAIO(w0, 128))
+	}
+
+	aios[0].Write([]byte("buffered"))
+	for _, a := range aios {
+		assert.NoError(a.Close())
+	}
+	assert.True(runtime.NumGoroutine() <= before, "goroutines leaked")
+	assert.Equal("buffered", w0.String())
+
+	a := aios[0]
+	n, err := a.Write([]byte("closed"))
+	assert.Equal(0, n)
+	assert.Equal(ErrAIOClosed, err)
+	assert.Equal(ErrAIOClosed, a.Flush())
+	assert.Equal(ErrAIOClosed, a.Close())
+	a.Reset(w0)
+	assert.Equal(ErrAIOClosed, a.Flush())
+}
+
+// syncbuf is the bytes.Buffer which is safe for the concurrent use.
+type syncbuf struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncbuf) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncbuf) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+func TestAIOFlushInterval(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		w      = &syncbuf{}
+		aio    = NewAIO(w, 1024, AIOFlushInterval(10*time.Millisecond))
+	)
+
+	aio.Write([]byte("abcdef"))
+	assert.Eventually(func() bool { return w.String() == "abcdef" }, time.Second, time.Millisecond)
+	assert.Equal(0, aio.Buffered())
+	aio.Write([]byte("ghi"))
+	assert.Eventually(func() bool { return w.String() == "abcdefghi" }, time.Second, time.Millisecond)
+
+	assert.NoError(aio.Close())
+	n, err := aio.Write([]byte("jkl"))
+	assert.Equal(0, n)
+	assert.Equal(ErrAIOClosed, err)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal("abcdefghi", w.String())
+}
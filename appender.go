@@ -1,18 +1,25 @@
 package log
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
 
 var (
-	HourlySuffix = ".20060102-15"
-	DailySuffix  = ".20060102"
+	HourlySuffix   = ".20060102-15"
+	DailySuffix    = ".20060102"
+	IntervalSuffix = ".20060102-150405"
 )
 
+// now is the clock of the rotation, which is replaced in the tests.
+var now = time.Now
+
 type Appender interface {
 	// Output will be invoked by Logger. The Logger input a formatted data
 	// to the appender using Output. And the data is only valid during the
@@ -21,6 +28,15 @@ type Appender interface {
 	Output(level Level, t time.Time, data []byte)
 }
 
+// TryAppender is an Appender which reports the failure of the output, the
+// decorators like RetryAppender use it to handle the failure.
+type TryAppender interface {
+	Appender
+	// TryOutput outputs the data like Output and returns the error when
+	// the output failed.
+	TryOutput(level Level, t time.Time, data []byte) error
+}
+
 type Flusher interface {
 	Flush() error
 }
@@ -35,13 +51,52 @@ type console struct {
 }
 
 func NewConsoleAppender() Appender {
-	return &console{Writer: os.Stdout}
+	return NewWriterAppender(os.Stdout)
+}
+
+// NewWriterAppender returns an appender which writes the formatted data to w.
+func NewWriterAppender(w io.Writer) Appender {
+	return &console{Writer: w}
 }
 
 func (c *console) Output(level Level, t time.Time, data []byte) {
+	c.TryOutput(level, t, data)
+}
+
+func (c *console) TryOutput(level Level, t time.Time, data []byte) error {
 	c.mu.Lock()
-	c.Write(data)
+	_, err := c.Write(data)
 	c.mu.Unlock()
+	return err
+}
+
+// unsafeConsole is the console without the lock, see
+// NewUnsafeConsoleAppender.
+type unsafeConsole struct {
+	io.Writer
+}
+
+// NewUnsafeConsoleAppender returns an appender which writes to the stdout
+// without the lock, which is UNSAFE for the concurrent logs. It is only
+// for the logger used by a single goroutine, e.g. the command line tools.
+func NewUnsafeConsoleAppender() Appender {
+	return NewUnsafeWriterAppender(os.Stdout)
+}
+
+// NewUnsafeWriterAppender returns an appender which writes to w without the
+// lock, which is UNSAFE for the concurrent logs, see
+// NewUnsafeConsoleAppender.
+func NewUnsafeWriterAppender(w io.Writer) Appender {
+	return &unsafeConsole{Writer: w}
+}
+
+func (c *unsafeConsole) Output(level Level, t time.Time, data []byte) {
+	c.Write(data)
+}
+
+func (c *unsafeConsole) TryOutput(level Level, t time.Time, data []byte) error {
+	_, err := c.Write(data)
+	return err
 }
 
 type RotateAppender struct {
@@ -51,46 +106,175 @@ type RotateAppender struct {
 	rtfn     func(time.Time) (time.Time, string)
 	w        io.Writer
 	file     *os.File
+	syncn    int           // fsync every syncn writes
+	syncd    time.Duration // fsync every syncd
+	writes   int
+	synced   time.Time
+	fsync    func(*os.File) error
+	flushw   bool                 // flush the buffer after every write
+	fadvise  func(*os.File) error // the hint of the closed file, see RotateFadvise
+	openhint func(*os.File) error // the hint of the opened file, see RotateSequential
+	noadvise bool                 // skip the fadvise when closing the file, see SetFadvise
+	onsignal bool                 // reopened by InstallReopenHandler, see RotateReopenOnSignal
+	clock    func() time.Time
+	closed   bool
+}
+
+// RotateOption configures the RotateAppender when it is created.
+type RotateOption func(*RotateAppender)
+
+// RotateClock set the clock of the appender, which decides the rotation
+// instead of the time of the logs, e.g. the tests drive the rotation by
+// advancing the clock without waiting for the real boundaries.
+func RotateClock(now func() time.Time) RotateOption {
+	return func(a *RotateAppender) { a.clock = now }
+}
+
+// RotateFadvise set the function giving the hint of the usage of the file
+// to the kernel when it is closed, instead of the default which drops the
+// file from the page cache by fadvise(FADV_DONTNEED) on linux and does
+// nothing on the other platforms. The nil function disables the hint.
+func RotateFadvise(fn func(*os.File) error) RotateOption {
+	return func(a *RotateAppender) { a.fadvise = fn }
+}
+
+// RotateSequential gives the hint of the sequential access of the file to
+// the kernel by fadvise(FADV_SEQUENTIAL) on linux when the file is opened,
+// which may improve the writeback of the large sequential writes. It does
+// nothing on the other platforms.
+func RotateSequential() RotateOption {
+	return func(a *RotateAppender) { a.openhint = fadviseSequential }
 }
 
-func hourly() time.Time {
-	return time.Now().Add(time.Hour).Truncate(time.Hour)
+// RotateReopenOnSignal makes the appender reopened by the handler installed
+// by InstallReopenHandler. The appender is tracked by the package until it
+// is closed, so it must be closed when it is not used any more, otherwise
+// it and its file are never released.
+func RotateReopenOnSignal() RotateOption {
+	return func(a *RotateAppender) { a.onsignal = true }
 }
 
-func daily() time.Time {
-	y, m, d := time.Now().Date()
+func hourly(t time.Time) time.Time {
+	return t.Add(time.Hour).Truncate(time.Hour)
+}
+
+func daily(t time.Time) time.Time {
+	y, m, d := t.Date()
 	return time.Date(y, m, d+1, 0, 0, 0, 0, time.Local)
 }
 
-func NewHourlyRotateAppender(filename string) (*RotateAppender, error) {
-	return NewHourlyRotateBufAppender(filename, 0)
+func newRotateAppender(filename string, opts []RotateOption) *RotateAppender {
+	a := &RotateAppender{filename: filepath.Clean(filename), fadvise: fadviseDontNeed}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
 }
 
-func NewHourlyRotateBufAppender(filename string, bufsize int) (*RotateAppender, error) {
-	a := &RotateAppender{
-		filename: filepath.Clean(filename),
-		rt:       hourly(),
+// now returns the current time of the clock of the appender.
+func (a *RotateAppender) now() time.Time {
+	if a.clock != nil {
+		return a.clock()
 	}
+	return now()
+}
 
+func NewHourlyRotateAppender(filename string, opts ...RotateOption) (*RotateAppender, error) {
+	return NewHourlyRotateBufAppender(filename, 0, opts...)
+}
+
+func NewHourlyRotateBufAppender(filename string, bufsize int, opts ...RotateOption) (*RotateAppender, error) {
+	a := newRotateAppender(filename, opts)
+	a.rt = hourly(a.now())
 	a.rtfn = func(t time.Time) (time.Time, string) {
-		return hourly(), t.Add(-time.Hour).Format(HourlySuffix)
+		return hourly(a.now()), t.Add(-time.Hour).Format(HourlySuffix)
 	}
 
 	return a.open(bufsize)
 }
 
-func NewDailyRotateAppender(filename string) (*RotateAppender, error) {
-	return NewDailyRotateBufAppender(filename, 0)
+// NewHourlyRotateBufAppenders returns the hourly RotateAppenders of the
+// log-levels in bufsizes, each of them writes to its own file with its own
+// buffer size, e.g. the big buffer for INFO and the unbuffered ERROR:
+//
+//	apps, err := NewHourlyRotateBufAppenders("logs/app.%s.log", map[Level]int{INFO: 64 << 10, ERROR: 0})
+//	lg.SetAppenderFunc(func(level Level) Appender { return apps[level] })
+//
+// The filename of a log-level is the pattern formatted with the lowercase
+// name of the log-level like `fmt.Sprintf`, e.g. "logs/app.info.log", it
+// must have exactly one %s. The opened appenders are closed if any of them
+// fails.
+func NewHourlyRotateBufAppenders(pattern string, bufsizes map[Level]int, opts ...RotateOption) (map[Level]Appender, error) {
+	if !levelPattern(pattern) {
+		return nil, fmt.Errorf("log: rotate pattern %q must have exactly one %%s", pattern)
+	}
+	apps := make(map[Level]Appender, len(bufsizes))
+	for level, bufsize := range bufsizes {
+		filename := fmt.Sprintf(pattern, strings.ToLower(LevelsToString[level]))
+		a, err := NewHourlyRotateBufAppender(filename, bufsize, opts...)
+		if err != nil {
+			if a != nil {
+				a.Close()
+			}
+			for _, app := range apps {
+				app.(*RotateAppender).Close()
+			}
+			return nil, err
+		}
+		apps[level] = a
+	}
+	return apps, nil
+}
+
+// levelPattern reports whether the pattern has exactly one verb which is
+// %s, the "%%" is the literal '%'.
+func levelPattern(pattern string) bool {
+	n := 0
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' {
+			continue
+		}
+		if i++; i < len(pattern) && pattern[i] == '%' {
+			continue
+		} else if i >= len(pattern) || pattern[i] != 's' {
+			return false
+		}
+		n++
+	}
+	return n == 1
+}
+
+func NewDailyRotateAppender(filename string, opts ...RotateOption) (*RotateAppender, error) {
+	return NewDailyRotateBufAppender(filename, 0, opts...)
+}
+
+func NewDailyRotateBufAppender(filename string, bufsize int, opts ...RotateOption) (*RotateAppender, error) {
+	a := newRotateAppender(filename, opts)
+	a.rt = daily(a.now())
+	a.rtfn = func(t time.Time) (time.Time, string) {
+		return daily(a.now()), t.Add(-24 * time.Hour).Format(DailySuffix)
+	}
+
+	return a.open(bufsize)
 }
 
-func NewDailyRotateBufAppender(filename string, bufsize int) (*RotateAppender, error) {
-	a := &RotateAppender{
-		filename: filepath.Clean(filename),
-		rt:       daily(),
+// NewIntervalRotateAppender returns a RotateAppender which rotates the file
+// every interval, e.g. every 5 minutes. The boundaries are the multiples of
+// interval since the zero time, and the rotated file is suffixed with the
+// start of its interval formatted by IntervalSuffix.
+func NewIntervalRotateAppender(filename string, interval time.Duration, opts ...RotateOption) (*RotateAppender, error) {
+	return NewIntervalRotateBufAppender(filename, interval, 0, opts...)
+}
+
+func NewIntervalRotateBufAppender(filename string, interval time.Duration, bufsize int, opts ...RotateOption) (*RotateAppender, error) {
+	if interval <= 0 {
+		return nil, errors.New("log: rotate interval must be positive")
 	}
 
+	a := newRotateAppender(filename, opts)
+	a.rt = a.now().Truncate(interval).Add(interval)
 	a.rtfn = func(t time.Time) (time.Time, string) {
-		return daily(), t.Add(-24 * time.Hour).Format(DailySuffix)
+		return a.now().Truncate(interval).Add(interval), t.Add(-interval).Format(IntervalSuffix)
 	}
 
 	return a.open(bufsize)
@@ -109,13 +293,61 @@ func (a *RotateAppender) open(bufsize int) (*RotateAppender, error) {
 	} else {
 		a.w = a.file
 	}
+	if err == nil {
+		a.hint()
+		if a.onsignal {
+			rotates.add(a)
+		}
+	}
 	return a, err
 }
 
+// rotates is the opened RotateAppenders created with RotateReopenOnSignal,
+// see InstallReopenHandler.
+var rotates = rotateset{m: make(map[*RotateAppender]struct{})}
+
+type rotateset struct {
+	mu sync.Mutex
+	m  map[*RotateAppender]struct{}
+}
+
+func (s *rotateset) add(a *RotateAppender) {
+	s.mu.Lock()
+	s.m[a] = struct{}{}
+	s.mu.Unlock()
+}
+
+func (s *rotateset) remove(a *RotateAppender) {
+	s.mu.Lock()
+	delete(s.m, a)
+	s.mu.Unlock()
+}
+
+// list returns a snapshot of the appenders.
+func (s *rotateset) list() []*RotateAppender {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	apps := make([]*RotateAppender, 0, len(s.m))
+	for a := range s.m {
+		apps = append(apps, a)
+	}
+	return apps
+}
+
+// Close closes the file and stops the background goroutine of the buffer,
+// the subsequent logs are dropped.
 func (a *RotateAppender) Close() error {
 	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	rotates.remove(a)
 	e := a.close()
-	a.mu.Unlock()
+	if bw, ok := a.w.(*AIO); ok {
+		bw.Close()
+	}
 	return e
 }
 
@@ -127,9 +359,15 @@ func (a *RotateAppender) close() error {
 		}
 	}
 
+	if a.file == nil { // the reopen failed
+		return e1
+	}
+
 	// ignore error
 	a.file.Sync()
-	fadvise(a.file)
+	if !a.noadvise && a.fadvise != nil {
+		a.fadvise(a.file)
+	}
 
 	if e2 = a.file.Close(); e2 != nil {
 		println("appender close filename: ", a.filename, "error: ", e2.Error())
@@ -145,6 +383,75 @@ func (a *RotateAppender) close() error {
 	return nil
 }
 
+// rotate renames the file to the target, the file is appended to the target
+// if the target exists, e.g. it was rotated before the restart of the
+// process in the same period, so that the logs before the restart are not
+// overwritten.
+func rotate(filename, target string) error {
+	if _, err := os.Lstat(target); os.IsNotExist(err) {
+		return os.Rename(filename, target)
+	}
+	dst, err := os.OpenFile(target, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(filename)
+	if err != nil {
+		dst.Close()
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	src.Close()
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	return os.Remove(filename)
+}
+
+// reopen opens the file after it is closed, the file is left nil and the
+// writer is not reset if it fails.
+func (a *RotateAppender) reopen() error {
+	file, err := os.OpenFile(a.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		println("appender open ", a.filename, "error: ", err.Error())
+		return err
+	}
+	a.file = file
+	a.hint()
+	a.reset(file)
+	return nil
+}
+
+// hint gives the hint of the opened file, see RotateSequential.
+func (a *RotateAppender) hint() {
+	if a.openhint != nil {
+		if err := a.openhint(a.file); err != nil {
+			println("appender fadvise ", a.filename, "error: ", err.Error())
+		}
+	}
+}
+
+// Reopen flushes the buffer, closes the file and opens the file by the
+// filename again without the rotation, e.g. after the file is renamed or
+// removed by the external tools like logrotate, the logs are written to the
+// recreated file after it. See InstallReopenHandler.
+func (a *RotateAppender) Reopen() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return errors.New("log: appender " + a.filename + " is closed")
+	}
+	err := a.close()
+	a.file = nil
+	if e := a.reopen(); e != nil {
+		return e
+	}
+	return err
+}
+
 func (a *RotateAppender) reset(file *os.File) {
 	if bw, ok := a.w.(Reseter); ok {
 		bw.Reset(file)
@@ -153,9 +460,21 @@ func (a *RotateAppender) reset(file *os.File) {
 	}
 }
 
-func (a *RotateAppender) Output(_ Level, t time.Time, data []byte) {
+func (a *RotateAppender) Output(level Level, t time.Time, data []byte) {
+	a.TryOutput(level, t, data)
+}
+
+func (a *RotateAppender) TryOutput(_ Level, t time.Time, data []byte) error {
 	a.mu.Lock()
-	if t.After(a.rt) {
+	if a.closed {
+		a.mu.Unlock()
+		return errors.New("log: appender " + a.filename + " is closed")
+	}
+	rt := t
+	if a.clock != nil {
+		rt = a.clock()
+	}
+	if !rt.Before(a.rt) {
 		var suffix string
 		a.rt, suffix = a.rtfn(a.rt)
 		filename := a.filename + suffix
@@ -163,24 +482,94 @@ func (a *RotateAppender) Output(_ Level, t time.Time, data []byte) {
 		if err != nil {
 			println("appender close ", a.filename, "error: ", err.Error())
 		}
-		if err = os.Rename(a.filename, filename); err != nil {
+		if err = rotate(a.filename, filename); err != nil {
 			println("appender rename ", filename, "error: ", err.Error())
 		}
-
-		a.file, err = os.OpenFile(a.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if err != nil {
-			println("appender open ", a.filename, "error: ", err.Error())
-		}
-		a.reset(a.file)
+		a.file = nil
+		a.reopen()
+	} else if a.file == nil {
+		a.reopen() // retry the failed reopen
 	}
 	if a.file == nil {
 		a.mu.Unlock()
-		return
+		return errors.New("log: appender file " + a.filename + " is not opened")
+	}
+	_, err := a.w.Write(data)
+	if a.flushw && err == nil {
+		if bw, ok := a.w.(Flusher); ok {
+			err = bw.Flush()
+		}
+	}
+	if a.syncn > 0 {
+		if a.writes++; a.writes >= a.syncn {
+			a.sync()
+		}
+	}
+	if a.syncd > 0 && a.now().Sub(a.synced) >= a.syncd {
+		a.sync()
 	}
-	a.w.Write(data)
+	a.mu.Unlock()
+	return err
+}
+
+// SetSyncEveryWrite set whether or not the buffered appender flushes its
+// buffer to the file after every write, so that the logs are visible to the
+// readers of the file immediately, e.g. in the tests. It does not fsync the
+// file, see SetSyncEvery.
+func (a *RotateAppender) SetSyncEveryWrite(enable bool) {
+	a.mu.Lock()
+	a.flushw = enable
+	a.mu.Unlock()
+}
+
+// SetFadvise set whether or not to drop the file from the page cache by
+// fadvise(FADV_DONTNEED) on linux when the file is closed by the rotation,
+// Reopen or Close, which is enabled by default. Disable it if the file is
+// read soon after it is closed, e.g. by a log shipper.
+func (a *RotateAppender) SetFadvise(enable bool) {
+	a.mu.Lock()
+	a.noadvise = !enable
+	a.mu.Unlock()
+}
+
+// SetSyncEvery set the appender to flush and fsync the file every n writes,
+// which makes the recently written logs durable even if the system crashes.
+// Zero disables it, which is the default.
+func (a *RotateAppender) SetSyncEvery(n int) {
+	a.mu.Lock()
+	a.syncn = n
+	a.writes = 0
+	a.mu.Unlock()
+}
+
+// SetSyncInterval set the appender to flush and fsync the file if the last
+// fsync is older than d when writing. Zero disables it, which is the default.
+func (a *RotateAppender) SetSyncInterval(d time.Duration) {
+	a.mu.Lock()
+	a.syncd = d
+	a.synced = a.now()
 	a.mu.Unlock()
 }
 
+func (a *RotateAppender) sync() {
+	a.writes = 0
+	a.synced = a.now()
+	if bw, ok := a.w.(Flusher); ok {
+		if err := bw.Flush(); err != nil {
+			println("appender sync flush error: ", err.Error())
+		}
+	}
+	var err error
+	if a.fsync != nil {
+		err = a.fsync(a.file)
+	} else {
+		err = a.file.Sync()
+	}
+	if err != nil {
+		println("appender sync ", a.filename, "error: ", err.Error())
+	}
+}
+
 func (a *RotateAppender) Flush() error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
@@ -189,3 +578,30 @@ func (a *RotateAppender) Flush() error {
 	}
 	return nil
 }
+
+// priorityFlush flushes the RotateAppender after the severe logs, see
+// NewPriorityFlushAppender.
+type priorityFlush struct {
+	*RotateAppender
+	from Level
+}
+
+// NewPriorityFlushAppender returns an appender which writes to the buffered
+// inner like itself, but flushes its buffer after every log as severe as or
+// more severe than flushFrom, e.g. with ERROR, the INFO logs are buffered for
+// the throughput while the ERROR and FATAL logs are written immediately.
+func NewPriorityFlushAppender(inner *RotateAppender, flushFrom Level) Appender {
+	return &priorityFlush{RotateAppender: inner, from: flushFrom}
+}
+
+func (a *priorityFlush) Output(level Level, t time.Time, data []byte) {
+	a.TryOutput(level, t, data)
+}
+
+func (a *priorityFlush) TryOutput(level Level, t time.Time, data []byte) error {
+	err := a.RotateAppender.TryOutput(level, t, data)
+	if err == nil && level.Enabled(a.from) {
+		err = a.Flush()
+	}
+	return err
+}
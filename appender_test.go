@@ -1,9 +1,17 @@
 package log
 
 import (
+	"bytes"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestHourlyRotateAppender(t *testing.T) {
@@ -13,7 +21,7 @@ func TestHourlyRotateAppender(t *testing.T) {
 		t.Fatalf("new hourly rotate appender error %v", err)
 	}
 
-	log := New("t")
+	log := newTestLogger("t")
 
 	defer func() {
 		app.Close()
@@ -52,6 +60,47 @@ func TestHourlyRotateBufAppender(t *testing.T) {
 	app.Output(DEBUG, time.Now(), []byte("2222\n"))
 }
 
+func TestUnsafeWriterAppender(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		buf    = bytes.NewBuffer(nil)
+		app    = NewUnsafeWriterAppender(buf)
+		lg     = newTestLogger("unsafe")
+	)
+	lg.SetAppender(app)
+	lg.SetFormat("[%l] %m")
+	lg.Info("a")
+	assert.NoError(app.(TryAppender).TryOutput(WARN, time.Now(), []byte("b\n")))
+	assert.Equal("[INFO] a\nb\n", buf.String())
+}
+
+func BenchmarkWriterAppender(b *testing.B) {
+	benchmarkWriterAppender(b, NewWriterAppender(ioutil.Discard))
+}
+
+func BenchmarkUnsafeWriterAppender(b *testing.B) {
+	benchmarkWriterAppender(b, NewUnsafeWriterAppender(ioutil.Discard))
+}
+
+func benchmarkWriterAppender(b *testing.B, app Appender) {
+	var (
+		lg   = newTestLogger("bench-writer")
+		data = []byte("2006-01-02 15:04:05 [INFO] BenchmarkWriterAppender running\n")
+		now  = time.Now()
+	)
+	lg.SetAppender(app)
+	b.Run("Output", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			app.Output(INFO, now, data)
+		}
+	})
+	b.Run("Logger", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			lg.Info("BenchmarkWriterAppender running")
+		}
+	})
+}
+
 func BenchmarkRotateAppenderBuf0(b *testing.B) {
 	const filename = "a.log"
 	app, err := NewHourlyRotateAppender(filename)
@@ -77,6 +126,32 @@ func BenchmarkRotateAppenderBuf0(b *testing.B) {
 	})
 }
 
+func benchmarkRotateSequential(b *testing.B, opts ...RotateOption) {
+	app, err := NewHourlyRotateBufAppender(filepath.Join(b.TempDir(), "a.log"), 64<<10, opts...)
+	if err != nil {
+		b.Fatalf("new hourly rotate appender error %v", err)
+	}
+	defer app.Close()
+
+	tt := time.Now()
+	data := bytes.Repeat([]byte("appender benchmark sequential write data\n"), 100)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		app.Output(DEBUG, tt, data)
+	}
+	app.Flush()
+}
+
+func BenchmarkRotateAppenderNoHint(b *testing.B) {
+	benchmarkRotateSequential(b)
+}
+
+func BenchmarkRotateAppenderSequential(b *testing.B) {
+	benchmarkRotateSequential(b, RotateSequential())
+}
+
 func BenchmarkRotateAppenderBuf4k(b *testing.B) {
 	const filename = "a.log"
 	app, err := NewHourlyRotateBufAppender(filename, 4096)
@@ -151,3 +226,414 @@ func BenchmarkRotateAppenderBuf16k(b *testing.B) {
 		}
 	})
 }
+
+func TestRotateAppenderSyncEvery(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		filename = filepath.Join(t.TempDir(), "a.log")
+		syncs    int
+		clock    = time.Now().Truncate(time.Hour).Add(10 * time.Minute)
+	)
+
+	app, err := NewHourlyRotateBufAppender(filename, 4096, RotateClock(func() time.Time { return clock }))
+	if err != nil {
+		t.Fatalf("new hourly rotate appender error %v", err)
+	}
+	defer app.Close()
+
+	app.fsync = func(*os.File) error { syncs++; return nil }
+	app.SetSyncEvery(3)
+	for i := 1; i <= 10; i++ {
+		app.Output(DEBUG, time.Now(), []byte("1111\n"))
+		assert.Equal(i/3, syncs, "writes %d", i)
+	}
+	b, err := ioutil.ReadFile(filename)
+	assert.Nil(err)
+	assert.Equal(strings.Repeat("1111\n", 9), string(b), "synced logs are flushed")
+
+	app.SetSyncEvery(0)
+	app.SetSyncInterval(time.Minute)
+	app.Output(DEBUG, clock, []byte("1111\n"))
+	assert.Equal(3, syncs)
+	clock = clock.Add(time.Minute)
+	app.Output(DEBUG, clock, []byte("1111\n"))
+	assert.Equal(4, syncs)
+	clock = clock.Add(time.Second)
+	app.Output(DEBUG, clock, []byte("1111\n"))
+	assert.Equal(4, syncs)
+	// the time of the logs does not affect the interval
+	app.Output(DEBUG, clock.Add(time.Hour), []byte("1111\n"))
+	assert.Equal(4, syncs)
+}
+
+func TestIntervalRotateAppender(t *testing.T) {
+	assert := assert.New(t)
+	_, err := NewIntervalRotateAppender("a.log", 0)
+	assert.Error(err)
+
+	var (
+		dir      = t.TempDir()
+		filename = filepath.Join(dir, "a.log")
+		clock    = time.Date(2020, 1, 2, 3, 4, 5, 0, time.Local)
+	)
+	defer func() { now = time.Now }()
+	now = func() time.Time { return clock }
+
+	app, err := NewIntervalRotateAppender(filename, 5*time.Minute)
+	if !assert.NoError(err) {
+		return
+	}
+	defer app.Close()
+	assert.Equal(time.Date(2020, 1, 2, 3, 5, 0, 0, time.Local), app.rt)
+
+	for _, s := range []string{"03:04:05", "03:04:59", "03:05:00", "03:09:59", "03:10:01", "03:21:00"} {
+		clock, _ = time.ParseInLocation("2006-01-02 15:04:05", "2020-01-02 "+s, time.Local)
+		app.Output(INFO, clock, []byte(s+"\n"))
+	}
+
+	read := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		assert.NoError(err)
+		return string(data)
+	}
+	assert.Equal("03:04:05\n03:04:59\n", read("a.log.20200102-030000"))
+	assert.Equal("03:05:00\n03:09:59\n", read("a.log.20200102-030500"))
+	assert.Equal("03:10:01\n", read("a.log.20200102-031000"))
+	assert.Equal("03:21:00\n", read("a.log"))
+	assert.Equal(time.Date(2020, 1, 2, 3, 25, 0, 0, time.Local), app.rt)
+}
+
+func TestRotateClock(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		dir      = t.TempDir()
+		filename = filepath.Join(dir, "a.log")
+		clock    = time.Date(2020, 1, 2, 3, 59, 0, 0, time.Local)
+	)
+
+	app, err := NewHourlyRotateAppender(filename, RotateClock(func() time.Time { return clock }))
+	if !assert.NoError(err) {
+		return
+	}
+	defer app.Close()
+
+	files := func() []string {
+		names, err := filepath.Glob(filename + "*")
+		assert.NoError(err)
+		return names
+	}
+
+	// the time of the logs does not decide the rotation.
+	app.Output(INFO, time.Now().Add(24*time.Hour), []byte("a\n"))
+	assert.Len(files(), 1)
+
+	clock = clock.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		app.Output(INFO, time.Now(), []byte("b\n"))
+	}
+	assert.Equal([]string{filename, filename + ".20200102-03"}, files())
+	assert.Equal(time.Date(2020, 1, 2, 5, 0, 0, 0, time.Local), app.rt)
+
+	data, err := ioutil.ReadFile(filename)
+	assert.NoError(err)
+	assert.Equal("b\nb\nb\n", string(data))
+}
+
+func TestRotateAppenderExistingTarget(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		dir      = t.TempDir()
+		filename = filepath.Join(dir, "a.log")
+		clock    = time.Date(2020, 1, 2, 3, 30, 0, 0, time.Local)
+		opt      = RotateClock(func() time.Time { return clock })
+	)
+
+	read := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		assert.NoError(err)
+		return string(data)
+	}
+
+	// the target was rotated before the restart.
+	assert.NoError(ioutil.WriteFile(filename+".20200102-03", []byte("rotated\n"), 0644))
+	app, err := NewHourlyRotateAppender(filename, opt)
+	if !assert.NoError(err) {
+		return
+	}
+	app.Output(INFO, clock, []byte("before restart\n"))
+	app.Close()
+
+	clock = clock.Add(10 * time.Minute)
+	app, err = NewHourlyRotateAppender(filename, opt)
+	if !assert.NoError(err) {
+		return
+	}
+	defer app.Close()
+	app.Output(INFO, clock, []byte("after restart\n"))
+	clock = clock.Add(time.Hour)
+	app.Output(INFO, clock, []byte("next\n"))
+
+	assert.Equal("rotated\nbefore restart\nafter restart\n", read("a.log.20200102-03"))
+	assert.Equal("next\n", read("a.log"))
+}
+
+func TestRotateAppenderReopenFailed(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		dir      = filepath.Join(t.TempDir(), "logs")
+		filename = filepath.Join(dir, "a.log")
+		clock    = time.Date(2020, 1, 2, 3, 59, 0, 0, time.Local)
+	)
+
+	app, err := NewHourlyRotateBufAppender(filename, 4096, RotateClock(func() time.Time { return clock }))
+	if !assert.NoError(err) {
+		return
+	}
+	defer app.Close()
+	app.Output(INFO, clock, []byte("a\n"))
+
+	// replace the directory by a file, so that the reopen fails.
+	assert.NoError(app.Flush())
+	assert.NoError(os.RemoveAll(dir))
+	assert.NoError(ioutil.WriteFile(dir, nil, 0644))
+	clock = clock.Add(time.Minute)
+	assert.Error(app.TryOutput(INFO, clock, []byte("dropped\n")))
+	assert.Nil(app.file)
+
+	assert.NoError(os.Remove(dir))
+	assert.NoError(os.Mkdir(dir, 0755))
+	assert.NoError(app.TryOutput(INFO, clock, []byte("b\n")))
+	assert.NoError(app.Flush())
+	data, err := ioutil.ReadFile(filename)
+	assert.NoError(err)
+	assert.Equal("b\n", string(data))
+}
+
+func TestRotateAppenderReopen(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		filename = filepath.Join(t.TempDir(), "a.log")
+	)
+
+	read := func() string {
+		data, err := ioutil.ReadFile(filename)
+		assert.NoError(err)
+		return string(data)
+	}
+
+	for _, bufsize := range []int{0, 4096} {
+		app, err := NewHourlyRotateBufAppender(filename, bufsize)
+		if !assert.NoError(err) {
+			return
+		}
+		app.Output(INFO, time.Now(), []byte("removed\n"))
+		assert.NoError(os.Remove(filename))
+		assert.NoError(app.Reopen())
+		assert.Equal("", read(), "the buffer is flushed to the removed file")
+		app.Output(INFO, time.Now(), []byte("recreated\n"))
+		assert.NoError(app.Flush())
+		assert.Equal("recreated\n", read())
+
+		assert.NoError(app.Reopen())
+		app.Output(INFO, time.Now(), []byte("appended\n"))
+		assert.NoError(app.Close())
+		assert.Equal("recreated\nappended\n", read())
+		assert.Error(app.Reopen())
+		assert.NoError(os.Remove(filename))
+	}
+}
+
+func TestNewHourlyRotateBufAppenders(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		dir    = t.TempDir()
+	)
+
+	apps, err := NewHourlyRotateBufAppenders(filepath.Join(dir, "app.%s.log"),
+		map[Level]int{INFO: 8192, ERROR: 0})
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Len(apps, 2)
+	info, errapp := apps[INFO].(*RotateAppender), apps[ERROR].(*RotateAppender)
+	defer info.Close()
+	defer errapp.Close()
+	assert.Equal(filepath.Join(dir, "app.info.log"), info.filename)
+	assert.Equal(filepath.Join(dir, "app.error.log"), errapp.filename)
+	if bw, ok := info.w.(*AIO); assert.True(ok) {
+		assert.Equal(8192, bw.Available())
+	}
+	assert.Equal(errapp.file, errapp.w, "unbuffered")
+
+	// the opened ones are closed when any of them fails
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0644))
+	apps, err = NewHourlyRotateBufAppenders(filepath.Join(dir, "file", "%s.log"), map[Level]int{INFO: 0})
+	assert.Error(err)
+	assert.Nil(apps)
+
+	for _, pattern := range []string{"app.log", "app.%s.%s.log", "app.%d.log", "app.%", "app.%%.log"} {
+		apps, err = NewHourlyRotateBufAppenders(filepath.Join(dir, pattern), map[Level]int{INFO: 0})
+		assert.Error(err, pattern)
+		assert.Nil(apps)
+	}
+	apps, err = NewHourlyRotateBufAppenders(filepath.Join(dir, "100%%.%s.log"), map[Level]int{INFO: 0})
+	if assert.NoError(err) {
+		assert.Equal(filepath.Join(dir, "100%.info.log"), apps[INFO].(*RotateAppender).filename)
+		apps[INFO].(*RotateAppender).Close()
+	}
+}
+
+func TestPriorityFlushAppender(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		filename = filepath.Join(t.TempDir(), "a.log")
+	)
+
+	inner, err := NewHourlyRotateBufAppender(filename, 4096)
+	if !assert.NoError(err) {
+		return
+	}
+	app := NewPriorityFlushAppender(inner, ERROR)
+	defer inner.Close()
+
+	read := func() string {
+		data, err := ioutil.ReadFile(filename)
+		assert.NoError(err)
+		return string(data)
+	}
+
+	app.Output(INFO, time.Now(), []byte("info\n"))
+	assert.Equal("", read(), "INFO is buffered")
+	app.Output(ERROR, time.Now(), []byte("error\n"))
+	assert.Equal("info\nerror\n", read(), "ERROR flushes the buffer")
+	app.Output(WARN, time.Now(), []byte("warn\n"))
+	assert.Equal("info\nerror\n", read())
+	app.Output(FATAL, time.Now(), []byte("fatal\n"))
+	assert.Equal("info\nerror\nwarn\nfatal\n", read())
+}
+
+func TestRotateAppenderSetFadvise(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		filename = filepath.Join(t.TempDir(), "a.log")
+		advised  []string
+		fadvise  = func(f *os.File) error {
+			advised = append(advised, f.Name())
+			return nil
+		}
+	)
+
+	app, err := NewHourlyRotateAppender(filename, RotateFadvise(fadvise))
+	if !assert.NoError(err) {
+		return
+	}
+	assert.NoError(app.Reopen())
+	assert.Equal([]string{filename}, advised, "enabled by default")
+
+	app.SetFadvise(false)
+	assert.NoError(app.Reopen())
+	assert.NoError(app.Close())
+	assert.Len(advised, 1)
+}
+
+func TestRotateFadvise(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		filename = filepath.Join(t.TempDir(), "a.log")
+		clock    = time.Date(2020, 1, 2, 3, 59, 0, 0, time.Local)
+		advised  []string
+	)
+
+	app, err := NewHourlyRotateAppender(filename,
+		RotateClock(func() time.Time { return clock }),
+		RotateFadvise(func(f *os.File) error {
+			advised = append(advised, f.Name())
+			return nil
+		}))
+	if !assert.NoError(err) {
+		return
+	}
+	app.Output(INFO, clock, []byte("a\n"))
+	clock = clock.Add(time.Minute)
+	app.Output(INFO, clock, []byte("b\n"))
+	assert.Equal([]string{filename}, advised, "called when rotating")
+	assert.NoError(app.Close())
+	assert.Equal([]string{filename, filename}, advised, "called when closing")
+
+	app, err = NewHourlyRotateAppender(filename, RotateFadvise(nil))
+	if assert.NoError(err) {
+		assert.NoError(app.Close())
+	}
+}
+
+func TestRotateSequential(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		filename = filepath.Join(t.TempDir(), "a.log")
+	)
+
+	app, err := NewHourlyRotateAppender(filename, RotateSequential())
+	if !assert.NoError(err) {
+		return
+	}
+	assert.NotNil(app.openhint)
+	app.Output(INFO, time.Now(), []byte("a\n"))
+	assert.NoError(app.Reopen())
+	app.Output(INFO, time.Now(), []byte("b\n"))
+	assert.NoError(app.Close())
+	data, err := ioutil.ReadFile(filename)
+	assert.NoError(err)
+	assert.Equal("a\nb\n", string(data))
+}
+
+func TestRotateAppenderSyncEveryWrite(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		filename = filepath.Join(t.TempDir(), "a.log")
+	)
+
+	app, err := NewHourlyRotateBufAppender(filename, 4096)
+	if !assert.NoError(err) {
+		return
+	}
+	defer app.Close()
+
+	read := func() string {
+		data, err := ioutil.ReadFile(filename)
+		assert.NoError(err)
+		return string(data)
+	}
+
+	app.Output(INFO, time.Now(), []byte("buffered\n"))
+	assert.Equal("", read())
+
+	app.SetSyncEveryWrite(true)
+	app.Output(INFO, time.Now(), []byte("visible\n"))
+	assert.Equal("buffered\nvisible\n", read())
+}
+
+func TestRotateAppenderClose(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		dir    = t.TempDir()
+		before = runtime.NumGoroutine()
+	)
+
+	for i := 0; i < 10; i++ {
+		app, err := NewHourlyRotateBufAppender(filepath.Join(dir, strconv.Itoa(i)+".log"), 4096)
+		if !assert.NoError(err) {
+			return
+		}
+		app.Output(INFO, time.Now(), []byte("closed\n"))
+		assert.NoError(app.Close())
+		assert.NoError(app.Close())
+		assert.Error(app.TryOutput(INFO, time.Now(), []byte("dropped\n")))
+		// the rotation does not reopen the closed appender.
+		assert.Error(app.TryOutput(INFO, time.Now().Add(2*time.Hour), []byte("dropped\n")))
+	}
+	assert.True(runtime.NumGoroutine() <= before, "goroutines leaked")
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "0.log"))
+	assert.NoError(err)
+	assert.Equal("closed\n", string(data))
+}
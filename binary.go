@@ -0,0 +1,152 @@
+package log
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// The binary record is encoded as:
+//
+//	uvarint  the length of the following bytes
+//	byte     the log-level
+//	int64    the time in unix nanoseconds, little endian
+//	[]byte   the message followed by the fields like " key=value"
+const binaryHeader = 1 + 8
+
+// binaryMaxRecord is the max length of the binary record, the larger length
+// is regarded as a corrupted record.
+const binaryMaxRecord = 64 << 20
+
+// ErrBinaryRecord is returned by BinaryLogReader when the record is malformed.
+var ErrBinaryRecord = errors.New("log: malformed binary record")
+
+// appendBinary appends the log encoded as a binary record to b.
+func appendBinary(b []byte, m *meta, level Level, tm time.Time, fields []Field, f string, v []interface{}) []byte {
+	// reserve the space of the longest header, then move the message
+	// backward after the actual header.
+	start := len(b)
+	b = append(b, make([]byte, binary.MaxVarintLen64+binaryHeader)...)
+	n := len(b)
+	b = appendMessage(b, m, f, v)
+	b = appendFields(b, fields, m.errchain)
+
+	var hdr [binary.MaxVarintLen64 + binaryHeader]byte
+	k := binary.PutUvarint(hdr[:], uint64(len(b)-n+binaryHeader))
+	hdr[k] = byte(level)
+	binary.LittleEndian.PutUint64(hdr[k+1:], uint64(tm.UnixNano()))
+	k += binaryHeader
+	copy(b[start:], hdr[:k])
+	copy(b[start+k:], b[n:])
+	return b[:len(b)-(n-start-k)]
+}
+
+// BinaryLogReader streams the records decoded from the binary logs written
+// by the logger whose format is set by SetBinaryFormat. It tolerates the
+// truncated last record of the file being written, which is kept and
+// decoded by the later Read once the rest of it is written.
+type BinaryLogReader struct {
+	r   io.Reader
+	buf []byte
+	off int // the start of the undecoded bytes in buf
+}
+
+// BinaryReader is the alias of BinaryLogReader.
+type BinaryReader = BinaryLogReader
+
+// NewBinaryLogReader returns a BinaryLogReader reading from r.
+func NewBinaryLogReader(r io.Reader) *BinaryLogReader {
+	return &BinaryLogReader{r: r, buf: make([]byte, 0, 4096)}
+}
+
+// NewBinaryReader returns a BinaryReader reading from r, it is the same as
+// NewBinaryLogReader.
+func NewBinaryReader(r io.Reader) *BinaryReader {
+	return NewBinaryLogReader(r)
+}
+
+// Read decodes the next record, the Message and Data of the record are the
+// message followed by the fields, which are only valid until the next Read.
+// It returns io.EOF if there is no more record, io.ErrUnexpectedEOF if the
+// last record is truncated.
+func (r *BinaryLogReader) Read() (*Record, error) {
+	for {
+		if rec, err := r.decode(); rec != nil || err != nil {
+			return rec, err
+		}
+		if err := r.fill(); err == io.EOF && r.off < len(r.buf) {
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// decode decodes the record in the buffered bytes, it returns nil if the
+// bytes are not a complete record.
+func (r *BinaryLogReader) decode() (*Record, error) {
+	b := r.buf[r.off:]
+	size, k := binary.Uvarint(b)
+	if k < 0 || (k > 0 && (size < binaryHeader || size > binaryMaxRecord)) {
+		return nil, ErrBinaryRecord
+	} else if k == 0 || uint64(len(b)-k) < size {
+		return nil, nil
+	}
+	b = b[k : k+int(size)]
+	r.off += k + int(size)
+	msg := b[binaryHeader:]
+	return &Record{
+		Level:   Level(b[0]),
+		Time:    time.Unix(0, int64(binary.LittleEndian.Uint64(b[1:]))),
+		Message: msg,
+		Data:    msg,
+	}, nil
+}
+
+// fill moves the undecoded bytes to the front of the buffer and reads more
+// bytes after them.
+func (r *BinaryLogReader) fill() error {
+	n := copy(r.buf, r.buf[r.off:])
+	r.buf, r.off = r.buf[:n], 0
+	if n == cap(r.buf) {
+		r.buf = append(r.buf, make([]byte, n)...)[:n]
+	}
+	m, err := r.r.Read(r.buf[n:cap(r.buf)])
+	r.buf = r.buf[:n+m]
+	if m > 0 {
+		return nil
+	} else if err == nil {
+		err = io.ErrNoProgress
+	}
+	return err
+}
+
+// ConvertToText decodes the binary logs from r and writes them to w in the
+// text form of the format-string, like Logger.SetFormat. The caller verbs are
+// rendered as '-' since the binary records do not carry them. The truncated
+// last record is skipped.
+func ConvertToText(r io.Reader, w io.Writer, format string) error {
+	var (
+		l   = &logger{}
+		m   = &meta{callerlvl: FATAL - 1}
+		f   = compile(format)
+		br  = NewBinaryLogReader(r)
+		buf []byte
+		v   = make([]interface{}, 1)
+	)
+	for {
+		rec, err := br.Read()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		v[0] = b2s(rec.Message)
+		buf = l.render(buf[:0], m, f, "", rec.Level, rec.Time, nil, v)
+		buf = append(buf, '\n')
+		if _, err = w.Write(buf); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBinaryFormat(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		buf    = bytes.NewBuffer(nil)
+		lg     = newTestLogger("binary")
+		long   = string(bytes.Repeat([]byte("x"), 300)) // 2 bytes varint
+		start  = time.Now()
+	)
+
+	lg.SetLevel(TRACE)
+	lg.SetAppender(NewWriterAppender(buf))
+	lg.SetBinaryFormat()
+	assert.Contains(lg.Describe(), " format=binary ")
+
+	lg.Info("hello")
+	lg.WithFields(Field{"k", "v"}).Errorf("failed %d", 1)
+	lg.Trace(long)
+	lg.Debug("")
+
+	r := NewBinaryReader(buf)
+	for _, expect := range []struct {
+		level Level
+		msg   string
+	}{
+		{INFO, "hello"},
+		{ERROR, "failed 1 k=v"},
+		{TRACE, long},
+		{DEBUG, ""},
+	} {
+		rec, err := r.Read()
+		if !assert.NoError(err) {
+			return
+		}
+		assert.Equal(expect.level, rec.Level)
+		assert.Equal(expect.msg, string(rec.Message))
+		assert.False(rec.Time.Before(start))
+		assert.False(rec.Time.After(time.Now()))
+	}
+	_, err := r.Read()
+	assert.Equal(io.EOF, err)
+
+	_, err = NewBinaryReader(bytes.NewReader([]byte{3, 0, 0, 0})).Read()
+	assert.Equal(ErrBinaryRecord, err)
+}
+
+func TestConvertToText(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		filename = filepath.Join(t.TempDir(), "a.log")
+		lg       = newTestLogger("convert")
+		tm       = time.Date(2021, 3, 4, 5, 6, 7, 0, time.Local)
+	)
+
+	app, err := NewHourlyRotateAppender(filename)
+	if err != nil {
+		t.Fatalf("new hourly rotate appender error %v", err)
+	}
+	defer app.Close()
+	lg.SetLevel(DEBUG)
+	lg.SetAppender(app)
+	lg.SetBinaryFormat()
+	lg.Info("first")
+	lg.WithFields(Field{"k", "v"}).Warnf("second %d", 2)
+	lg.Error("truncated")
+	assert.Nil(app.Flush())
+
+	b, err := ioutil.ReadFile(filename)
+	if !assert.Nil(err) || !assert.True(len(b) > 3, "%d bytes", len(b)) {
+		return
+	}
+	b = b[:len(b)-3]
+
+	out := bytes.NewBuffer(nil)
+	assert.Nil(ConvertToText(bytes.NewReader(b), out, "[%l] %c %m"))
+	assert.Equal("[INFO] - first\n[WARN] - second 2 k=v\n", out.String())
+
+	// the truncated record is decoded once the rest of it is written.
+	var (
+		rec *Record
+		rb  = bytes.NewBuffer(nil)
+		r   = NewBinaryLogReader(rb)
+		m   = &meta{}
+	)
+	record := appendBinary(nil, m, DEBUG, tm, nil, "", []interface{}{"resumed"})
+	rb.Write(record[:5])
+	_, err = r.Read()
+	assert.Equal(io.ErrUnexpectedEOF, err)
+	rb.Write(record[5:])
+	rec, err = r.Read()
+	if assert.NoError(err) {
+		assert.Equal(DEBUG, rec.Level)
+		assert.True(tm.Equal(rec.Time))
+		assert.Equal("resumed", string(rec.Message))
+	}
+	_, err = r.Read()
+	assert.Equal(io.EOF, err)
+}
+
+func BenchmarkBinaryFormat(b *testing.B) {
+	lg := newTestLogger("bench-binary")
+	lg.SetAppender(&null{})
+	lg.SetBinaryFormat()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lg.Infof("BenchmarkBinaryFormat running %s %d", "go go go", 12345678)
+	}
+}
@@ -0,0 +1,91 @@
+package log
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"unsafe"
+)
+
+// sourceroot is the prefix trimmed from the callers of %C with the trailing
+// '/', see SetSourceRoot. Its actual type is string.
+var sourceroot atomic.Value
+
+// SetSourceRoot set the root directory of the source code, e.g. the
+// directory of the go.mod on the build machine, which is trimmed from the
+// callers output by %C, so that they are the relative paths like
+// "internal/foo/bar.go" instead of the long absolute ones leaking the
+// directory structure of the build machine. The callers out of the root
+// are output as is. Empty disables it, which is the default.
+func SetSourceRoot(root string) {
+	if root != "" {
+		root = filepath.ToSlash(filepath.Clean(root))
+		if !strings.HasSuffix(root, "/") {
+			root += "/"
+		}
+	}
+	sourceroot.Store(root)
+}
+
+// trimSourceRoot returns the caller relative to the source root if it is
+// under the root.
+func trimSourceRoot(caller string) string {
+	if root, _ := sourceroot.Load().(string); root != "" {
+		return strings.TrimPrefix(caller, root)
+	}
+	return caller
+}
+
+// CallSite caches the caller of a single log statement, so that the logger
+// resolves the caller by `runtime.Caller` only once for the statement, see
+// Logger.AtSite. It is useful for the extremely hot log statement whose
+// format resolves the caller.
+//
+// The cache assumes that all the logs through it are emitted by the same
+// statement, it is not detected when the assumption is broken, and the
+// cached caller is reported for the other statements. So the CallSite
+// should be declared next to the statement, e.g.
+//
+//	var site log.CallSite
+//	for ... {
+//		logger.AtSite(&site).Infof("processed %d", n)
+//	}
+//
+// The zero value of CallSite is ready to use, it must not be copied after
+// first use.
+type CallSite struct {
+	frame unsafe.Pointer // *callframe
+}
+
+type callframe struct {
+	pc   uintptr
+	file string
+	line int
+}
+
+// caller returns the file and line of the caller like `runtime.Caller`, skip
+// is the number of the frames to ascend from the caller of caller. The
+// result is cached in the s if s is not nil.
+func (s *CallSite) caller(skip int) (string, int) {
+	_, file, line := s.callerPC(skip + 1)
+	return file, line
+}
+
+// callerPC returns the program counter, file and line of the caller like
+// caller.
+func (s *CallSite) callerPC(skip int) (uintptr, string, int) {
+	if s != nil {
+		if f := (*callframe)(atomic.LoadPointer(&s.frame)); f != nil {
+			return f.pc, f.file, f.line
+		}
+	}
+	pc, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return 0, "???", 0
+	}
+	if s != nil {
+		atomic.StorePointer(&s.frame, unsafe.Pointer(&callframe{pc: pc, file: file, line: line}))
+	}
+	return pc, file, line
+}
@@ -0,0 +1,87 @@
+package log
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallSite(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		r      = &recordap{}
+		lg     = newTestLogger("callsite")
+		site   CallSite
+		lines  []string
+	)
+
+	lg.SetFormat("%c:%L %m")
+	lg.SetAppender(d)
+	for i := 0; i < 3; i++ {
+		lg.AtSite(&site).Info("hot")
+		lines = append(lines, d.d)
+	}
+	assert.True(strings.HasPrefix(lines[0], "callsite_test.go:"), lines[0])
+	assert.Equal(lines[0], lines[1])
+	assert.Equal(lines[0], lines[2])
+	assert.NotNil(site.frame)
+
+	lg.Info("plain")
+	assert.True(strings.HasPrefix(d.d, "callsite_test.go:"), d.d)
+	assert.NotEqual(lines[0][:strings.IndexByte(lines[0], ' ')], d.d[:strings.IndexByte(d.d, ' ')])
+
+	lg.SetAppender(r)
+	lg.AtSite(&site).WithFields(Field{"k", "v"}).Info("hot")
+	assert.Equal(lines[0], fmt.Sprintf("%s:%d hot\n", filepath.Base(r.r.Caller), r.r.Line))
+}
+
+func benchmarkCallSite(b *testing.B, site *CallSite) {
+	lg := newTestLogger("bench-callsite")
+	lg.SetAppender(&null{})
+	lg.SetFormat("%F %T %c:%L [%l] %m")
+	hot := lg.AtSite(site)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hot.Infof("BenchmarkCallSite running %s %d", "go go go", 12345678)
+	}
+}
+
+func BenchmarkCallSiteUncached(b *testing.B) {
+	benchmarkCallSite(b, nil)
+}
+
+func BenchmarkCallSiteCached(b *testing.B) {
+	benchmarkCallSite(b, &CallSite{})
+}
+
+func TestSetSourceRoot(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("sourceroot")
+	)
+	defer SetSourceRoot("")
+
+	_, file, _, _ := runtime.Caller(0)
+	lg.SetAppender(d)
+	lg.SetFormat("%C")
+	lg.Info("a")
+	assert.Equal(file+"\n", d.d)
+
+	SetSourceRoot(filepath.Dir(filepath.Dir(file)))
+	lg.Info("b")
+	assert.Equal(filepath.Base(filepath.Dir(file))+"/callsite_test.go\n", d.d)
+	SetSourceRoot(filepath.Dir(file) + "/")
+	lg.Info("c")
+	assert.Equal("callsite_test.go\n", d.d)
+
+	SetSourceRoot("/nonexistent")
+	lg.Info("d")
+	assert.Equal(file+"\n", d.d, "out of the root")
+}
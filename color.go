@@ -0,0 +1,80 @@
+package log
+
+import (
+	"os"
+	"time"
+)
+
+// LevelColors are the ANSI escape sequences which colorize the logs of the
+// log-levels in the colorized console, see NewColorConsoleAppender.
+var LevelColors = map[Level]string{
+	FATAL: "\x1b[35m",
+	ERROR: "\x1b[31m",
+	WARN:  "\x1b[33m",
+	INFO:  "\x1b[32m",
+	DEBUG: "\x1b[36m",
+	TRACE: "\x1b[90m",
+}
+
+const colorReset = "\x1b[0m"
+
+type colorConsole struct {
+	console
+	color bool
+}
+
+// NewColorConsoleAppender returns an appender which writes the logs to the
+// stdout colorized by their log-levels, see LevelColors. Whether or not to
+// colorize is decided when it is created, the precedence is:
+//
+//	FORCE_COLOR   colorize if it is set to a non-empty value except "0"
+//	NO_COLOR      do not colorize if it is set to a non-empty value
+//	otherwise     colorize if the stdout is a terminal
+func NewColorConsoleAppender() Appender {
+	return &colorConsole{console: console{Writer: os.Stdout}, color: colorEnabled(os.Stdout)}
+}
+
+// colorEnabled reports whether or not to colorize the logs written to f.
+func colorEnabled(f *os.File) bool {
+	if v := os.Getenv("FORCE_COLOR"); v != "" && v != "0" {
+		return true
+	} else if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(f)
+}
+
+// isTerminal reports whether f is a character device, which is the best
+// guess of a terminal without the syscalls of the platforms.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func (c *colorConsole) Output(level Level, t time.Time, data []byte) {
+	c.TryOutput(level, t, data)
+}
+
+func (c *colorConsole) TryOutput(level Level, t time.Time, data []byte) error {
+	color := LevelColors[level]
+	if !c.color || color == "" {
+		return c.console.TryOutput(level, t, data)
+	}
+	b := appendColor(getbuf(), color, data)
+	err := c.console.TryOutput(level, t, b)
+	putbuf(b)
+	return err
+}
+
+// appendColor appends the data wrapped by the color and the reset sequence
+// to b, the trailing newline is kept after the reset sequence.
+func appendColor(b []byte, color string, data []byte) []byte {
+	n := len(data)
+	if n > 0 && data[n-1] == '\n' {
+		n--
+	}
+	b = append(b, color...)
+	b = append(b, data[:n]...)
+	b = append(b, colorReset...)
+	return append(b, data[n:]...)
+}
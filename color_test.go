@@ -0,0 +1,48 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColorEnabled(t *testing.T) {
+	assert := assert.New(t)
+	f, err := os.Create(filepath.Join(t.TempDir(), "a.log"))
+	if err != nil {
+		t.Fatalf("create file error %v", err)
+	}
+	defer f.Close()
+
+	t.Setenv("FORCE_COLOR", "")
+	t.Setenv("NO_COLOR", "")
+	assert.False(colorEnabled(f), "not a terminal")
+
+	t.Setenv("NO_COLOR", "1")
+	assert.False(colorEnabled(f))
+
+	t.Setenv("FORCE_COLOR", "1")
+	assert.True(colorEnabled(f), "FORCE_COLOR takes precedence over NO_COLOR")
+
+	t.Setenv("FORCE_COLOR", "0")
+	assert.False(colorEnabled(f))
+}
+
+func TestColorConsole(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		buf    = bytes.NewBuffer(nil)
+		c      = &colorConsole{console: console{Writer: buf}, color: true}
+	)
+	c.Output(ERROR, time.Now(), []byte("failed\n"))
+	assert.Equal("\x1b[31mfailed\x1b[0m\n", buf.String())
+
+	buf.Reset()
+	c.color = false
+	c.Output(ERROR, time.Now(), []byte("failed\n"))
+	assert.Equal("failed\n", buf.String())
+}
@@ -0,0 +1,157 @@
+package log
+
+import (
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/lrita/ratelimit"
+)
+
+// Config is the whole configuration of a logger, see Logger.Configure. The
+// zero value of every field is applied as is, e.g. the zero Level is FATAL,
+// unless its comment says otherwise.
+type Config struct {
+	Level Level
+	// Format is the format of the log-levels absent in Formats.
+	Format  string
+	Formats map[Level]string
+	// Appender is the appender of the log-levels absent in Appenders.
+	Appender  Appender
+	Appenders map[Level]Appender
+	// Ratelimit is the rate limit of the log-levels absent in Ratelimits,
+	// which is shared by them like SetRatelimit. Zero means no limit.
+	Ratelimit  int64
+	Ratelimits map[Level]int64
+	// CallerMinLevel is the least severe log-level which resolves the
+	// caller, see Logger.SetCallerMinLevel. Nil means TRACE, which is the
+	// default of the loggers.
+	CallerMinLevel *Level
+	// FatalExitCode is the exit code when fatal log printing, zero means
+	// the package FatalExitCode.
+	FatalExitCode      int
+	IndentContinuation bool
+	FieldSeparator     string
+	// ErrorChain renders the error arguments with their chains, see
+	// Logger.SetErrorChain.
+	ErrorChain bool
+	// Decorations is the prefix and suffix wrapping the %m of the
+	// log-levels, see Logger.SetLevelDecoration.
+	Decorations map[Level][2]string
+}
+
+// meta builds the meta of the configuration.
+func (c *Config) meta() *meta {
+	m := &meta{
+		level:     c.Level,
+		callerlvl: TRACE,
+		indent:    c.IndentContinuation,
+		sep:       c.FieldSeparator,
+		errchain:  c.ErrorChain,
+		appenders: make(map[Level]Appender, len(LevelsToString)),
+		formats:   make(map[Level]*layout, len(LevelsToString)),
+		limits:    make(map[Level]*ratelimit.Bucket, len(LevelsToString)),
+	}
+	if c.CallerMinLevel != nil {
+		m.callerlvl = *c.CallerMinLevel
+	}
+	if c.FatalExitCode != 0 {
+		code := c.FatalExitCode
+		m.exitcode = &code
+	}
+	if len(c.Decorations) != 0 {
+		m.decos = make(map[Level]decoration, len(c.Decorations))
+		for level, d := range c.Decorations {
+			if d[0] != "" || d[1] != "" {
+				m.decos[level] = decoration{prefix: d[0], suffix: d[1]}
+			}
+		}
+	}
+
+	var (
+		format = compile(c.Format)
+		bucket *ratelimit.Bucket
+	)
+	if c.Ratelimit > 0 {
+		bucket = ratelimit.NewBucketWithRate(jitterRate(float64(c.Ratelimit), RatelimitJitter), 1)
+	}
+	for level := range LevelsToString {
+		if f, ok := c.Formats[level]; ok {
+			m.formats[level] = compile(f)
+		} else {
+			m.formats[level] = format
+		}
+		if app, ok := c.Appenders[level]; ok {
+			m.appenders[level] = app
+		} else {
+			m.appenders[level] = c.Appender
+		}
+		if limit, ok := c.Ratelimits[level]; ok {
+			if limit > 0 {
+				m.limits[level] = ratelimit.NewBucketWithRate(jitterRate(float64(limit), RatelimitJitter), 1)
+			}
+		} else if bucket != nil {
+			m.limits[level] = bucket
+		}
+	}
+	return m
+}
+
+func (l *logger) Configure(c Config) {
+	cm := c.meta()
+	l.tree.Lock()
+	l.configureInternal(true, cm)
+	l.tree.Unlock()
+}
+
+// configureInternal applies the attributes of cm to the logger by a single
+// swap of its meta, and propagates them to the children which have not set
+// them by themselves.
+func (l *logger) configureInternal(detach bool, cm *meta) {
+	l.l.Lock()
+	var (
+		m    = *(*meta)(atomic.LoadPointer(&l.meta))
+		mask = uint16(detachall)
+	)
+	if detach {
+		m.detach |= detachall
+	} else if mask &^= m.detach; mask == 0 {
+		l.l.Unlock()
+		return
+	}
+	if mask&detachlvl != 0 {
+		m.level, m.bound = cm.level, cm.bound
+	}
+	if mask&detachapp != 0 {
+		m.appenders, m.named = cm.appenders, cm.named
+	}
+	if mask&detachfmt != 0 {
+		m.formats = cm.formats
+	}
+	if mask&detachlmt != 0 {
+		m.limits, m.samples, m.floors = cm.limits, cm.samples, cm.floors
+	}
+	if mask&detachclr != 0 {
+		m.callerlvl = cm.callerlvl
+	}
+	if mask&detachext != 0 {
+		m.exitcode = cm.exitcode
+	}
+	if mask&detachind != 0 {
+		m.indent = cm.indent
+	}
+	if mask&detachsep != 0 {
+		m.sep = cm.sep
+	}
+	if mask&detachdec != 0 {
+		m.decos = cm.decos
+	}
+	if mask&detacherr != 0 {
+		m.errchain = cm.errchain
+	}
+	atomic.StorePointer(&l.meta, unsafe.Pointer(&m))
+	children := l.children
+	l.l.Unlock()
+	for _, child := range children {
+		child.configureInternal(false, cm)
+	}
+}
@@ -0,0 +1,152 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// prefixap counts the logs which do not start with its prefix.
+type prefixap struct {
+	prefix     []byte
+	mismatched int64
+}
+
+func (a *prefixap) Output(level Level, t time.Time, data []byte) {
+	if !bytes.HasPrefix(data, a.prefix) {
+		atomic.AddInt64(&a.mismatched, 1)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		e      = &dap{}
+		lg     = newTestLogger("configure")
+		caller = ERROR
+	)
+
+	child := lg.New("child")
+	child.SetFormat("child %m")
+	lg.Configure(Config{
+		Level:          INFO,
+		Format:         "%m",
+		Formats:        map[Level]string{ERROR: "[%l] %m"},
+		Appender:       d,
+		Appenders:      map[Level]Appender{ERROR: e},
+		Ratelimits:     map[Level]int64{WARN: 1},
+		CallerMinLevel: &caller,
+		Decorations:    map[Level][2]string{ERROR: {">", "<"}},
+	})
+	assert.Equal(`logger="configure" level=INFO`+
+		` format={FATAL:"%m", ERROR:"[%l] %m", WARN:"%m", INFO:"%m", DEBUG:"%m", TRACE:"%m"}`+
+		` appender=*log.dap`+
+		` ratelimit={FATAL:none, ERROR:none, WARN:1/s, INFO:none, DEBUG:none, TRACE:none}`, lg.Describe())
+
+	lg.Debug("dropped")
+	assert.Equal("", d.d)
+	lg.Info("info")
+	assert.Equal("info\n", d.d)
+	lg.Error("error")
+	assert.Equal("[ERROR] >error<\n", e.d)
+	lg.Warn("warn")
+	lg.Warn("limited")
+	assert.Equal("warn\n", d.d)
+
+	// the child keeps its own format and inherits the others.
+	child.Info("info")
+	assert.Equal("child info\n", d.d)
+	assert.Equal(INFO, child.Level())
+}
+
+func TestConfigureCallerMinLevel(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("configure-caller")
+	)
+
+	lg.Configure(Config{Level: TRACE, Format: "%c %m", Appender: d})
+	lg.Trace("trace")
+	assert.Equal("config_test.go trace\n", d.d)
+
+	caller := WARN
+	lg.Configure(Config{Level: TRACE, Format: "%c %m", Appender: d, CallerMinLevel: &caller})
+	lg.Info("info")
+	assert.Equal("- info\n", d.d)
+	lg.Warn("warn")
+	assert.Equal("config_test.go warn\n", d.d)
+
+	caller = FATAL
+	lg.Configure(Config{Level: TRACE, Format: "%c %m", Appender: d, CallerMinLevel: &caller})
+	lg.Error("error")
+	assert.Equal("- error\n", d.d)
+}
+
+func TestConfigureErrorChain(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("configure-errchain")
+		err    = &opaqueError{"outer", errors.New("inner")}
+	)
+
+	child := lg.New("child")
+	lg.Configure(Config{Level: TRACE, Format: "%m", Appender: d, ErrorChain: true})
+	lg.Info(err)
+	assert.Equal("outer: inner\n", d.d)
+	child.Info(err)
+	assert.Equal("outer: inner\n", d.d)
+
+	// the child configured by itself keeps its own error chain
+	other := lg.New("other")
+	other.Configure(Config{Level: TRACE, Format: "%m", Appender: d})
+	other.Info(err)
+	assert.Equal("outer\n", d.d)
+	lg.SetErrorChain(false)
+	child.Info(err)
+	assert.Equal("outer\n", d.d)
+	other.Configure(Config{Level: TRACE, Format: "%m", Appender: d, ErrorChain: true})
+	other.Info(err)
+	assert.Equal("outer: inner\n", d.d)
+}
+
+func TestConfigureAtomic(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		a      = &prefixap{prefix: []byte("A ")}
+		b      = &prefixap{prefix: []byte("B ")}
+		lg     = newTestLogger("configure-atomic")
+		wg     sync.WaitGroup
+		stop   int32
+		ca     = Config{Level: TRACE, Format: "A %m", Appender: a}
+		cb     = Config{Level: TRACE, Format: "B %m", Appender: b}
+	)
+
+	lg.Configure(ca)
+	child := lg.New("child")
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&stop) == 0 {
+				lg.Info("parent")
+				child.Info("child")
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		lg.Configure(ca)
+		lg.Configure(cb)
+	}
+	atomic.StoreInt32(&stop, 1)
+	wg.Wait()
+	assert.Equal(int64(0), atomic.LoadInt64(&a.mismatched))
+	assert.Equal(int64(0), atomic.LoadInt64(&b.mismatched))
+}
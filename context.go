@@ -0,0 +1,24 @@
+package log
+
+import "context"
+
+// ctxkey is the key of the logger stored in the context.
+type ctxkey struct{}
+
+// ContextWithLogger returns a copy of ctx which stores the logger, e.g. the
+// request-scoped logger with the fields of the request attached, which is
+// retrieved by FromContext in the downstream code.
+func ContextWithLogger(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, ctxkey{}, l)
+}
+
+// FromContext returns the logger stored in ctx by ContextWithLogger, it
+// returns the global logger if there is none, it never returns nil.
+func FromContext(ctx context.Context) Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(ctxkey{}).(Logger); ok && l != nil {
+			return l
+		}
+	}
+	return Default()
+}
@@ -0,0 +1,34 @@
+package log
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextWithLogger(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("context")
+	)
+
+	lg.SetFormat("[%l] %m")
+	lg.SetAppender(d)
+	ctx := ContextWithLogger(context.Background(), lg.WithFields(F("request_id", 1)))
+	FromContext(ctx).Info("a")
+	assert.Equal("[INFO] a request_id=1\n", d.d)
+
+	// falls back to the global logger
+	for _, ctx := range []context.Context{
+		context.Background(),
+		ContextWithLogger(context.Background(), nil),
+		nil,
+	} {
+		l := FromContext(ctx)
+		if assert.NotNil(l) {
+			assert.Equal(log, l.(*entry).logger)
+		}
+	}
+}
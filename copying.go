@@ -0,0 +1,55 @@
+package log
+
+import "time"
+
+// copyingAppender copies the data before passing it to the inner appender,
+// see NewCopyingAppender.
+type copyingAppender struct {
+	inner Appender
+}
+
+// copyingRecordAppender is the copyingAppender whose inner appender is a
+// RecordAppender.
+type copyingRecordAppender struct {
+	copyingAppender
+}
+
+// NewCopyingAppender returns an appender which passes a copy of the data to
+// the inner appender, so that the inner appender can retain the data, e.g.
+// it outputs the data asynchronously. The data passed to Appender.Output is
+// only valid during the invoking, because the buffer of the data is reused
+// by the later logs after the Output returns, see SetPoolEnabled. The
+// Record passed to the inner RecordAppender is copied too, except the
+// values of its fields.
+func NewCopyingAppender(inner Appender) Appender {
+	if _, ok := inner.(RecordAppender); ok {
+		return &copyingRecordAppender{copyingAppender{inner: inner}}
+	}
+	return &copyingAppender{inner: inner}
+}
+
+func (a *copyingAppender) Output(level Level, t time.Time, data []byte) {
+	a.inner.Output(level, t, append([]byte(nil), data...))
+}
+
+func (a *copyingRecordAppender) OutputRecord(r *Record) {
+	c := *r
+	// the message follows the data in the same buffer, see Logger.
+	b := make([]byte, 0, len(r.Data)+len(r.Message))
+	b = append(b, r.Data...)
+	b = append(b, r.Message...)
+	c.Data, c.Message = b[:len(r.Data):len(r.Data)], b[len(r.Data):]
+	c.Fields = append([]Field(nil), r.Fields...)
+	a.inner.(RecordAppender).OutputRecord(&c)
+}
+
+func (a *copyingRecordAppender) NeedsCaller() bool {
+	return needsCaller(a.inner)
+}
+
+func (a *copyingAppender) Flush() error {
+	if f, ok := a.inner.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package log
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type retainrecordap struct {
+	retainap
+	records []*Record
+}
+
+func (a *retainrecordap) OutputRecord(r *Record) {
+	a.records = append(a.records, r)
+}
+
+func TestCopyingAppender(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		a      = &retainap{}
+		r      = &retainrecordap{}
+		lg     = newTestLogger("copying")
+	)
+
+	lg.SetFormat("%m")
+	lg.SetAppender(NewCopyingAppender(a), INFO)
+	lg.SetAppender(NewCopyingAppender(r), WARN)
+	lg.SetAppender(&null{}, ERROR)
+	for i := 0; i < 16; i++ {
+		lg.Infof("log %d", i)
+		lg.WithFields(Field{"k", i}).Warnf("log %d", i)
+	}
+	// the buffers are reused by the later logs.
+	for i := 0; i < 16; i++ {
+		lg.Errorf("overwrite %d", i)
+	}
+
+	assert.Len(a.data, 16)
+	for i, data := range a.data {
+		assert.Equal(fmt.Sprintf("log %d\n", i), string(data))
+	}
+	assert.Len(r.records, 16)
+	for i, rec := range r.records {
+		assert.Equal(fmt.Sprintf("log %d k=%d\n", i, i), string(rec.Data))
+		assert.Equal(fmt.Sprintf("log %d", i), string(rec.Message))
+		assert.Equal([]Field{{"k", i}}, rec.Fields)
+	}
+	assert.Nil(NewCopyingAppender(a).(Flusher).Flush())
+}
@@ -0,0 +1,70 @@
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+func (l *logger) LogConfig() {
+	l.dolog(nil, "%s", INFO, l.Describe())
+}
+
+// Describe returns the configuration like:
+//
+//	logger="name" level=DEBUG format="%F %T [%l] %m" appender=*log.console ratelimit=none
+//
+// The value of an attribute is listed per log-level like
+// format={FATAL:"%m", ERROR:"[%l] %m", ...} if it is not the same for all
+// the log-levels.
+func (l *logger) Describe() string {
+	var (
+		b strings.Builder
+		m = (*meta)(atomic.LoadPointer(&l.meta))
+	)
+	fmt.Fprintf(&b, "logger=%q level=%s", l.name, LevelsToString[m.threshold()])
+	describeLevels(&b, "format", func(level Level) string {
+		if f := m.formats[level]; f != nil && f.binary {
+			return "binary"
+		} else if f != nil {
+			return strconv.Quote(f.fmt)
+		}
+		return `""`
+	})
+	describeLevels(&b, "appender", func(level Level) string {
+		return fmt.Sprintf("%T", m.appenders[level])
+	})
+	describeLevels(&b, "ratelimit", func(level Level) string {
+		if limit := m.limits[level]; limit != nil {
+			return strconv.FormatFloat(limit.Rate(), 'g', -1, 64) + "/s"
+		}
+		return "none"
+	})
+	return b.String()
+}
+
+// describeLevels writes the values of all log-levels as "key=value" if they
+// are the same, otherwise as "key={FATAL:value0, ERROR:value1, ...}".
+func describeLevels(b *strings.Builder, key string, fn func(Level) string) {
+	var (
+		values = make([]string, 0, TRACE+1)
+		same   = true
+	)
+	for level := FATAL; level <= TRACE; level++ {
+		values = append(values, fn(level))
+		same = same && values[level] == values[0]
+	}
+	if same {
+		fmt.Fprintf(b, " %s=%s", key, values[0])
+		return
+	}
+	fmt.Fprintf(b, " %s={", key)
+	for level := FATAL; level <= TRACE; level++ {
+		if level != FATAL {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(b, "%s:%s", LevelsToString[level], values[level])
+	}
+	b.WriteByte('}')
+}
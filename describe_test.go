@@ -0,0 +1,55 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogConfig(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("config")
+	)
+
+	lg.SetLevel(WARN)
+	lg.SetAppender(d)
+	lg.SetFormat("%m")
+	lg.LogConfig()
+	assert.Equal("", d.d, "INFO is disabled")
+
+	lg.SetLevel(DEBUG)
+	lg.LogConfig()
+	assert.Equal(`logger="config" level=DEBUG format="%m" appender=*log.dap ratelimit=none`+"\n", d.d)
+
+	lg.SetFormat("[%l] %m", ERROR)
+	lg.SetAppender(nil, TRACE)
+	lg.LogConfig()
+	assert.Equal(`logger="config" level=DEBUG`+
+		` format={FATAL:"%m", ERROR:"[%l] %m", WARN:"%m", INFO:"%m", DEBUG:"%m", TRACE:"%m"}`+
+		` appender={FATAL:*log.dap, ERROR:*log.dap, WARN:*log.dap, INFO:*log.dap, DEBUG:*log.dap, TRACE:<nil>}`+
+		` ratelimit=none`+"\n", d.d)
+}
+
+func TestDescribe(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		parent = newTestLogger("parent")
+	)
+
+	parent.SetLevel(INFO)
+	parent.SetFormat("%m")
+	parent.SetAppender(&dap{})
+	child := parent.New("child")
+	assert.Equal(`logger="parent" level=INFO format="%m" appender=*log.dap ratelimit=none`, parent.Describe())
+	assert.Equal(`logger="child" level=INFO format="%m" appender=*log.dap ratelimit=none`, child.Describe())
+
+	child.SetLevel(TRACE)
+	child.SetRatelimit(100, ERROR)
+	child.SetAppender(&null{}, DEBUG, TRACE)
+	assert.Equal(`logger="child" level=TRACE format="%m"`+
+		` appender={FATAL:*log.dap, ERROR:*log.dap, WARN:*log.dap, INFO:*log.dap, DEBUG:*log.null, TRACE:*log.null}`+
+		` ratelimit={FATAL:none, ERROR:100/s, WARN:none, INFO:none, DEBUG:none, TRACE:none}`, child.Describe())
+	assert.Equal(`logger="parent" level=INFO format="%m" appender=*log.dap ratelimit=none`, parent.Describe())
+}
@@ -0,0 +1,50 @@
+package log
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+)
+
+// levelvar is the counters of a log-level published by EnableExpvar.
+type levelvar struct {
+	emitted uint64
+	dropped uint64
+	bytes   uint64
+}
+
+var (
+	expvarEnabled uint32
+	expvarOnce    sync.Once
+	// levelvars is indexed by the log-level masked by 7, so that the
+	// indexing never panics for the invalid log-levels.
+	levelvars [8]levelvar
+)
+
+// EnableExpvar publishes the counters of the logs of all the loggers as the
+// expvar variable "log", which is visible at /debug/vars, like:
+//
+//	"log": {"INFO": {"emitted": 10, "dropped": 2, "bytes": 420}, ...}
+//
+// The emitted and bytes count the logs output to the appenders and the
+// length of their formatted data, the dropped counts the logs dropped by
+// the rate limit. The counters are only updated after it is enabled.
+func EnableExpvar() {
+	expvarOnce.Do(func() {
+		expvar.Publish("log", expvar.Func(expvars))
+	})
+	atomic.StoreUint32(&expvarEnabled, 1)
+}
+
+func expvars() interface{} {
+	vars := make(map[string]map[string]uint64, len(LevelsToString))
+	for level, name := range LevelsToString {
+		v := &levelvars[level&7]
+		vars[name] = map[string]uint64{
+			"emitted": atomic.LoadUint64(&v.emitted),
+			"dropped": atomic.LoadUint64(&v.dropped),
+			"bytes":   atomic.LoadUint64(&v.bytes),
+		}
+	}
+	return vars
+}
@@ -0,0 +1,40 @@
+package log
+
+import (
+	"encoding/json"
+	"expvar"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnableExpvar(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		lg     = newTestLogger("expvar")
+		vars   map[string]map[string]uint64
+	)
+	lg.SetAppender(&null{})
+	lg.SetFormat("%m")
+	lg.Warn("not counted")
+
+	EnableExpvar()
+	EnableExpvar()
+	defer atomic.StoreUint32(&expvarEnabled, 0)
+	before := expvars().(map[string]map[string]uint64)
+
+	lg.Warn("12345")
+	lg.Warn("1234")
+	lg.SetRatelimit(1, ERROR)
+	for i := 0; i < 3; i++ {
+		lg.Error("e")
+	}
+
+	assert.Nil(json.Unmarshal([]byte(expvar.Get("log").String()), &vars))
+	assert.Equal(uint64(2), vars["WARN"]["emitted"]-before["WARN"]["emitted"])
+	assert.Equal(uint64(11), vars["WARN"]["bytes"]-before["WARN"]["bytes"])
+	assert.Equal(uint64(0), vars["WARN"]["dropped"]-before["WARN"]["dropped"])
+	assert.Equal(uint64(1), vars["ERROR"]["emitted"]-before["ERROR"]["emitted"])
+	assert.Equal(uint64(2), vars["ERROR"]["dropped"]-before["ERROR"]["dropped"])
+}
@@ -4,6 +4,10 @@ package log
 
 import "os"
 
-func fadvise(_ *os.File) error {
+func fadviseDontNeed(_ *os.File) error {
+	return nil
+}
+
+func fadviseSequential(_ *os.File) error {
 	return nil
 }
@@ -9,6 +9,10 @@ import (
 	"golang.org/x/sys/unix"
 )
 
-func fadvise(f *os.File) error {
+func fadviseDontNeed(f *os.File) error {
 	return unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_DONTNEED)
 }
+
+func fadviseSequential(f *os.File) error {
+	return unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_SEQUENTIAL)
+}
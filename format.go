@@ -0,0 +1,103 @@
+package log
+
+import (
+	"fmt"
+	stdlog "log"
+	"strings"
+	"unicode/utf8"
+)
+
+// verb is a directive of a compiled format-string. The verb with zero op is
+// a literal run of the format-string, which is stored in lit.
+type verb struct {
+	op  byte
+	lit string
+}
+
+// layout is a format-string compiled into a list of verbs, so that the
+// logger does not need to parse the format-string for every log. The binary
+// layout encodes the logs in the binary records, see appendBinary. The
+// layout without the caller verbs skips the caller setup when rendering.
+type layout struct {
+	fmt    string
+	verbs  []verb
+	binary bool
+	caller bool // has any of %C, %c and %L
+}
+
+// compile parses the format-string into a layout, the unknown verbs are
+// skipped.
+func compile(format string) *layout {
+	f, _ := compileChecked(format)
+	return f
+}
+
+// compileChecked parses the format-string into a layout like compile, and
+// returns the error of the first unknown verb or the trailing '%'.
+func compileChecked(format string) (*layout, error) {
+	var (
+		n   = len(format)
+		f   = &layout{fmt: format}
+		err error
+	)
+
+	for i := 0; i < n; i++ {
+		lasti := i
+		for i < n && format[i] != '%' {
+			i++
+		}
+		if i > lasti {
+			f.verbs = append(f.verbs, verb{lit: format[lasti:i]})
+		}
+		if i >= n { // done processing format string
+			break
+		} else if i == n-1 { // the trailing '%' is a literal
+			f.verbs = append(f.verbs, verb{lit: "%"})
+			if err == nil {
+				err = fmt.Errorf("log: format %q has the trailing %%", format)
+			}
+			break
+		}
+
+		i++ // skip '%'
+
+		switch c := format[i]; c {
+		case 'C', 'c', 'L':
+			f.verbs = append(f.verbs, verb{op: c})
+			f.caller = true
+		case 'm', 'l', 'F', 'D', 'd', 'T', 'a', 'A', 'b', 'B', 'i', 'h', 'e', 'S':
+			f.verbs = append(f.verbs, verb{op: c})
+		case '%':
+			f.verbs = append(f.verbs, verb{lit: "%"})
+		case 'n':
+			f.verbs = append(f.verbs, verb{lit: "\n"})
+		default:
+			if err == nil {
+				r, _ := utf8.DecodeRuneInString(format[i:])
+				err = fmt.Errorf("log: format %q has the unknown verb %%%c", format, r)
+			}
+		}
+	}
+	return f, err
+}
+
+// flagsFormat translates the flags of the standard library to the format,
+// see SetFlags. It returns the unsupported flags as well.
+func flagsFormat(flags int) (format string, unsupported int) {
+	var parts []string
+	if flags&stdlog.Ldate != 0 {
+		parts = append(parts, "%F")
+	}
+	if flags&stdlog.Ltime != 0 {
+		parts = append(parts, "%T")
+	}
+	parts = append(parts, "[%l]")
+	if flags&stdlog.Lshortfile != 0 {
+		parts = append(parts, "%c:%L:")
+	} else if flags&stdlog.Llongfile != 0 {
+		parts = append(parts, "%C:%L:")
+	}
+	parts = append(parts, "%m")
+	supported := stdlog.Ldate | stdlog.Ltime | stdlog.Lshortfile | stdlog.Llongfile
+	return strings.Join(parts, " "), flags &^ supported
+}
@@ -0,0 +1,40 @@
+//go:build go1.18
+// +build go1.18
+
+package log
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func FuzzFormatParser(f *testing.F) {
+	for _, format := range []string{
+		"%m", "%l", "%C", "%c", "%L", "%%", "%n", "%F", "%D", "%T", "%a", "%A",
+		"%b", "%B", "%d", "%i", "%h", "%e", "%F %T [%l] %m", "%", "a%", "%%%",
+		"%z", "%\xff", "中%文%", "%S", "%-S", "%-S %m", "%m %",
+	} {
+		f.Add(format, "message")
+	}
+
+	lg := newTestLogger("fuzz").(*logger)
+	lg.SetLevel(TRACE)
+	lg.SetAppender(&null{})
+	tm := time.Now()
+	f.Fuzz(func(t *testing.T, format, msg string) {
+		l := compile(format)
+		if l.fmt != format {
+			t.Fatalf("compile(%q) lost the format-string", format)
+		}
+		m := (*meta)(lg.meta)
+		b := lg.render(nil, m, l, "", INFO, tm, nil, []interface{}{msg})
+		if strings.HasSuffix(format, "%") && !strings.HasSuffix(format, "%%") &&
+			len(b) == 0 {
+			t.Fatalf("the trailing %% of %q is dropped", format)
+		}
+		lg.SetFormat(format)
+		lg.Infof(msg, 1, "a")
+		lg.Info(msg)
+	})
+}
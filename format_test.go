@@ -0,0 +1,138 @@
+package log
+
+import (
+	"bytes"
+	stdlog "log"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	f := compile("%F %T [%l] %m%%%n%")
+	assert.Equal("%F %T [%l] %m%%%n%", f.fmt)
+	assert.Equal([]verb{
+		{op: 'F'}, {lit: " "}, {op: 'T'}, {lit: " ["}, {op: 'l'}, {lit: "] "},
+		{op: 'm'}, {lit: "%"}, {lit: "\n"}, {lit: "%"},
+	}, f.verbs)
+
+	assert.Empty(compile("").verbs)
+	assert.Equal([]verb{{lit: "a"}, {lit: "b"}}, compile("a%zb").verbs)
+
+	assert.False(f.caller)
+	for _, format := range []string{"%C", "[%c]", "%m %L"} {
+		assert.True(compile(format).caller, format)
+	}
+}
+
+func TestSetFormatChecked(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("formatchecked")
+	)
+
+	lg.SetAppender(d)
+	for _, format := range []string{"", "%F %T [%l] %m", "%%%n%S %e", "100%% %m"} {
+		assert.NoError(lg.SetFormatChecked(format), format)
+	}
+	lg.Info("a")
+	assert.Equal("100% a\n", d.d)
+
+	for format, msg := range map[string]string{
+		"%m %z":    `log: format "%m %z" has the unknown verb %z`,
+		"%m %é":    `log: format "%m %é" has the unknown verb %é`,
+		"[%l] %m%": `log: format "[%l] %m%" has the trailing %`,
+		"%q %m%":   `log: format "%q %m%" has the unknown verb %q`,
+	} {
+		assert.EqualError(lg.SetFormatChecked(format, INFO), msg)
+	}
+	lg.Info("b")
+	assert.Equal("100% b\n", d.d, "the invalid format is not set")
+}
+
+func TestSetFlags(t *testing.T) {
+	assert := assert.New(t)
+	for flags, format := range map[int]string{
+		0:                                    "[%l] %m",
+		stdlog.LstdFlags:                     "%F %T [%l] %m",
+		stdlog.LstdFlags | stdlog.Lshortfile: "%F %T [%l] %c:%L: %m",
+		stdlog.Ltime | stdlog.Llongfile:      "%T [%l] %C:%L: %m",
+		stdlog.Llongfile | stdlog.Lshortfile: "[%l] %c:%L: %m",
+	} {
+		f, unsupported := flagsFormat(flags)
+		assert.Equal(format, f, "%#x", flags)
+		assert.Zero(unsupported)
+	}
+	_, unsupported := flagsFormat(stdlog.LstdFlags | stdlog.Lmicroseconds | stdlog.LUTC)
+	assert.Equal(stdlog.Lmicroseconds|stdlog.LUTC, unsupported)
+
+	var (
+		buf = &bytes.Buffer{}
+		m   = (*meta)(atomic.LoadPointer(&log.meta))
+	)
+	stderr = buf
+	defer func() {
+		stderr = os.Stderr
+		log.set(detachfmt, func(mm *meta) { mm.formats = m.formats })
+	}()
+	SetFlags(stdlog.Ldate | stdlog.Lmicroseconds)
+	assert.Equal("%F [%l] %m", (*meta)(atomic.LoadPointer(&log.meta)).formats[INFO].fmt)
+	assert.Equal("log: SetFlags ignores the unsupported flags 0x4\n", buf.String())
+}
+
+func TestElapsedFormat(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		lg     = newTestLogger("elapsed").(*logger)
+		m      = (*meta)(lg.meta)
+		f      = compile("%e %m")
+		tm     = time.Now()
+	)
+	assert.Equal([]verb{{op: 'e'}, {lit: " "}, {op: 'm'}}, f.verbs)
+	assert.Equal("- a", string(lg.render(nil, m, f, "", INFO, tm, nil, []interface{}{"a"})))
+	tm = tm.Add(1500 * time.Millisecond)
+	assert.Equal("1.5s b", string(lg.render(nil, m, f, "", INFO, tm, nil, []interface{}{"b"})))
+	tm = tm.Add(2 * time.Minute)
+	assert.Equal("2m0s c", string(lg.render(nil, m, f, "", INFO, tm, nil, []interface{}{"c"})))
+
+	other := newTestLogger("elapsed-other").(*logger)
+	assert.Equal("- d", string(other.render(nil, m, f, "", INFO, tm, nil, []interface{}{"d"})),
+		"the elapsed time is per logger")
+}
+
+const benchformat = "%F %T %a %b [%l] %m"
+
+func BenchmarkLayoutParsed(b *testing.B) {
+	var (
+		lg  = newTestLogger("bench-layout").(*logger)
+		m   = (*meta)(lg.meta)
+		tm  = time.Now()
+		buf = make([]byte, 0, 256)
+	)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf = lg.render(buf[:0], m, compile(benchformat), "", INFO, tm, nil, nil)
+	}
+}
+
+func BenchmarkLayoutCompiled(b *testing.B) {
+	var (
+		lg  = newTestLogger("bench-layout").(*logger)
+		m   = (*meta)(lg.meta)
+		f   = compile(benchformat)
+		tm  = time.Now()
+		buf = make([]byte, 0, 256)
+	)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf = lg.render(buf[:0], m, f, "", INFO, tm, nil, nil)
+	}
+}
@@ -1,40 +1,258 @@
 package log
 
-// New return a sub logger of global logger
+import (
+	"fmt"
+	"io"
+	stdlog "log"
+	"time"
+)
+
+// Default returns the global logger as a Logger, the changes through it are
+// applied to the global logger, e.g. Default().SetLevel(WARN) is the same as
+// SetLevel(WARN).
+func Default() Logger {
+	// the global logger skips the frames of the package-level functions,
+	// which are absent when it is called directly.
+	return &entry{logger: log, skip: -1}
+}
+
+// New return a sub logger of global logger, the same logger is returned for
+// the same name with the configuration set by the previous callers, see
+// Logger.New
 func New(name string) Logger {
 	return log.New(name)
 }
 
+// Clone returns an independent logger seeded with the configuration of
+// global logger
+func Clone() Logger {
+	return log.Clone()
+}
+
+// WithError return a log handler of global logger which attaches the error
+func WithError(err error) Logger {
+	return Default().WithError(err)
+}
+
 // SetLevel log level for global logger
 func SetLevel(level Level) {
 	log.SetLevel(level)
 }
 
+// WithLevel set the log level of global logger and returns the function
+// restoring the previous one
+func WithLevel(level Level) (restore func()) {
+	return log.WithLevel(level)
+}
+
 // SetAppender set append for global logger
 func SetAppender(appender Appender, levels ...Level) {
 	log.SetAppender(appender, levels...)
 }
 
+// SwapAppender set append for global logger and returns the previous ones
+func SwapAppender(appender Appender, levels ...Level) map[Level]Appender {
+	return log.SwapAppender(appender, levels...)
+}
+
+// AddAppender adds the appender to the log-levels of global logger besides
+// their current appenders
+func AddAppender(appender Appender, levels ...Level) {
+	log.AddAppender(appender, levels...)
+}
+
+// AddNamedAppender adds the appender registered by the name to the
+// log-levels of global logger, see RemoveAppender
+func AddNamedAppender(name string, appender Appender, levels ...Level) {
+	log.AddNamedAppender(name, appender, levels...)
+}
+
+// RemoveAppender removes the appender registered by the name from global
+// logger
+func RemoveAppender(name string) {
+	log.RemoveAppender(name)
+}
+
+// ReplaceAllAppenders set all log-levels of global logger to use the
+// appender only
+func ReplaceAllAppenders(appender Appender) {
+	log.ReplaceAllAppenders(appender)
+}
+
+// SetAppenderFunc set every log-level of global logger to use the appender
+// returned by fn
+func SetAppenderFunc(fn func(Level) Appender) {
+	log.SetAppenderFunc(fn)
+}
+
+// SetOutput set the output destination for global logger
+func SetOutput(w io.Writer) {
+	log.SetOutput(w)
+}
+
 // SetFormat set format-string for global logger
 func SetFormat(fmt string, levels ...Level) {
 	log.SetFormat(fmt, levels...)
 }
 
+// SetFormatChecked set format-string for global logger, it returns the
+// error of the invalid format without setting it
+func SetFormatChecked(fmt string, levels ...Level) error {
+	return log.SetFormatChecked(fmt, levels...)
+}
+
+// SetBinaryFormat set the log-levels of global logger to encode the logs in
+// the binary records
+func SetBinaryFormat(levels ...Level) {
+	log.SetBinaryFormat(levels...)
+}
+
 // SetRatelimit set log rate limit for global logger
 func SetRatelimit(limit int64, levels ...Level) {
 	log.SetRatelimit(limit, levels...)
 }
 
+// SetRatelimitSample set log rate limit for global logger, which samples 1
+// in k of the logs over the limit
+func SetRatelimitSample(limit, k int64, levels ...Level) {
+	log.SetRatelimitSample(limit, k, levels...)
+}
+
+// BindLevel makes global logger read the log-level from the level, see
+// Logger.BindLevel
+func BindLevel(level *int32) {
+	log.BindLevel(level)
+}
+
+// SetRatelimitFloor guarantees at least n logs per second of the log-levels
+// of global logger pass through the rate limit
+func SetRatelimitFloor(n int64, levels ...Level) {
+	log.SetRatelimitFloor(n, levels...)
+}
+
+// SetRatelimitNotice emits a WARN log of global logger every interval for
+// the logs dropped by the rate limit, see Logger.SetRatelimitNotice
+func SetRatelimitNotice(interval time.Duration) {
+	log.SetRatelimitNotice(interval)
+}
+
 // SetCallDepth set callee stack depth
 func SetCallDepth(d int) {
 	log.SetCallDepth(d + 1)
 }
 
+// SetCallerMinLevel set the least severe log-level which resolves the caller
+// for global logger
+func SetCallerMinLevel(level Level) {
+	log.SetCallerMinLevel(level)
+}
+
+// SetFatalExitCode set the exit code of global logger when fatal log printing
+func SetFatalExitCode(code int) {
+	log.SetFatalExitCode(code)
+}
+
+// SetIndentContinuation set whether or not the global logger indents the
+// continuation lines of a multi-line message
+func SetIndentContinuation(enable bool) {
+	log.SetIndentContinuation(enable)
+}
+
+// SetFieldSeparator set the separator joining the arguments of the logs of
+// global logger
+func SetFieldSeparator(sep string) {
+	log.SetFieldSeparator(sep)
+}
+
+// SetErrorChain set whether or not global logger renders the error arguments
+// with their wrapped errors
+func SetErrorChain(enable bool) {
+	log.SetErrorChain(enable)
+}
+
+// SetLevelDecoration set the literals wrapping the %m of the log-level of
+// global logger
+func SetLevelDecoration(level Level, prefix, suffix string) {
+	log.SetLevelDecoration(level, prefix, suffix)
+}
+
 // IsDebugEnabled indicates whether debug level is enabled
 func IsDebugEnabled() bool {
 	return log.IsDebugEnabled()
 }
 
+// WouldLog indicates whether a log of the given log-level would be emitted
+// by global logger now
+func WouldLog(level Level) bool {
+	return log.WouldLog(level)
+}
+
+// LogConfig emits an INFO log describing the configuration of global logger
+func LogConfig() {
+	log.LogConfig()
+}
+
+// Recover logs the panic in progress with the stack trace by global logger
+// and panics again, it must be called directly by defer like
+// `defer log.Recover()`.
+func Recover() {
+	if r := recover(); r != nil {
+		log.recovered(&entry{logger: log, skip: 1}, r, true)
+	}
+}
+
+// RecoverAndContinue logs the panic in progress with the stack trace by
+// global logger and stops the panicking, it must be called directly by defer
+// like `defer log.RecoverAndContinue()`.
+func RecoverAndContinue() {
+	if r := recover(); r != nil {
+		log.recovered(&entry{logger: log, skip: 1}, r, false)
+	}
+}
+
+// Configure applies the whole configuration to global logger at once
+func Configure(c Config) {
+	log.Configure(c)
+}
+
+// Describe returns a human-readable summary of the configuration of global
+// logger
+func Describe() string {
+	return log.Describe()
+}
+
+// SetFlags set the format of global logger translated from the flags of
+// the standard library, which eases the migration from it:
+//
+//	Ldate       %F
+//	Ltime       %T
+//	Llongfile   %C:%L:
+//	Lshortfile  %c:%L:, which overrides Llongfile
+//
+// The log-level is always output, e.g. LstdFlags is the default format
+// "%F %T [%l] %m", and LstdFlags|Lshortfile is "%F %T [%l] %c:%L: %m". The
+// other flags like Lmicroseconds and LUTC are ignored with a warning on the
+// stderr.
+func SetFlags(flags int) {
+	format, unsupported := flagsFormat(flags)
+	if unsupported != 0 {
+		fmt.Fprintf(stderr, "log: SetFlags ignores the unsupported flags %#x\n", unsupported)
+	}
+	log.SetFormat(format)
+}
+
+// StdlibAdapter returns a logger of standard library which writes to global
+// logger at the given log-level
+func StdlibAdapter(level Level) *stdlog.Logger {
+	return Default().StdlibAdapter(level)
+}
+
+// LevelWriter returns an io.Writer which writes every line to global logger
+// at the given log-level
+func LevelWriter(level Level) io.Writer {
+	return Default().LevelWriter(level)
+}
+
 func Fatal(v ...interface{}) {
 	log.Fatal(v...)
 }
@@ -63,6 +281,10 @@ func Fatalf(fmt string, v ...interface{}) {
 	log.Fatalf(fmt, v...)
 }
 
+func FatalWithCode(code int, v ...interface{}) {
+	log.FatalWithCode(code, v...)
+}
+
 func Errorf(fmt string, v ...interface{}) {
 	log.Errorf(fmt, v...)
 }
@@ -82,3 +304,51 @@ func Debugf(fmt string, v ...interface{}) {
 func Tracef(fmt string, v ...interface{}) {
 	log.Tracef(fmt, v...)
 }
+
+func FatalKV(msg string, kv ...Field) {
+	log.FatalKV(msg, kv...)
+}
+
+func ErrorKV(msg string, kv ...Field) {
+	log.ErrorKV(msg, kv...)
+}
+
+func InfoKV(msg string, kv ...Field) {
+	log.InfoKV(msg, kv...)
+}
+
+func WarnKV(msg string, kv ...Field) {
+	log.WarnKV(msg, kv...)
+}
+
+func DebugKV(msg string, kv ...Field) {
+	log.DebugKV(msg, kv...)
+}
+
+func TraceKV(msg string, kv ...Field) {
+	log.TraceKV(msg, kv...)
+}
+
+func FatalAt(t time.Time, v ...interface{}) {
+	log.FatalAt(t, v...)
+}
+
+func ErrorAt(t time.Time, v ...interface{}) {
+	log.ErrorAt(t, v...)
+}
+
+func InfoAt(t time.Time, v ...interface{}) {
+	log.InfoAt(t, v...)
+}
+
+func WarnAt(t time.Time, v ...interface{}) {
+	log.WarnAt(t, v...)
+}
+
+func DebugAt(t time.Time, v ...interface{}) {
+	log.DebugAt(t, v...)
+}
+
+func TraceAt(t time.Time, v ...interface{}) {
+	log.TraceAt(t, v...)
+}
@@ -6,6 +6,7 @@ require (
 	github.com/lrita/cache v1.0.1
 	github.com/lrita/ratelimit v0.0.0-20190723030019-81504bd89bc5
 	github.com/stretchr/testify v1.7.1
+	golang.org/x/sys v0.6.0
 )
 
 require (
@@ -13,6 +14,5 @@ require (
 	github.com/intel-go/cpuid v0.0.0-20220614022739-219e067757cb // indirect
 	github.com/lrita/numa v1.0.2 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	golang.org/x/sys v0.6.0 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
 )
@@ -0,0 +1,161 @@
+package log
+
+import (
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// GzipFlushInterval is the interval of flushing the gzip stream of the
+// GzipFileAppender, the logs are visible to the readers of the file after
+// they are flushed.
+var GzipFlushInterval = time.Second
+
+// GzipFileAppender is an appender which writes the logs to the file
+// compressed by gzip on the fly. The gzip stream is flushed every
+// GzipFlushInterval, by Flush and closed by Close, so that the file is
+// always a valid gzip, which may be incomplete.
+//
+// Reading the file during writing, e.g. by `zcat`, gets the logs flushed so
+// far followed by the error like "unexpected end of file", because the
+// trailer of the gzip stream is only written by Close and Reopen.
+type GzipFileAppender struct {
+	mu       sync.Mutex
+	filename string
+	file     *os.File
+	zw       *gzip.Writer
+	dirty    bool // has the data written after the last flushing
+	closed   bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewGzipFileAppender returns a GzipFileAppender which appends the logs to
+// the file, the gzip stream is appended as a new member of the existing
+// file, which is still a valid gzip.
+func NewGzipFileAppender(filename string) (*GzipFileAppender, error) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	a := &GzipFileAppender{
+		filename: filename,
+		file:     file,
+		zw:       gzip.NewWriter(file),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go a.loop(GzipFlushInterval)
+	return a, nil
+}
+
+func (a *GzipFileAppender) loop(interval time.Duration) {
+	defer close(a.done)
+	if interval <= 0 {
+		<-a.stop
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.Flush()
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+func (a *GzipFileAppender) Output(level Level, t time.Time, data []byte) {
+	a.TryOutput(level, t, data)
+}
+
+func (a *GzipFileAppender) TryOutput(_ Level, _ time.Time, data []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return errors.New("log: appender " + a.filename + " is closed")
+	} else if a.file == nil {
+		if err := a.reopen(); err != nil { // retry the failed reopen
+			return err
+		}
+	}
+	a.dirty = true
+	_, err := a.zw.Write(data)
+	return err
+}
+
+// Flush flushes the gzip stream to the file.
+func (a *GzipFileAppender) Flush() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.dirty || a.file == nil {
+		return nil
+	}
+	a.dirty = false
+	return a.zw.Flush()
+}
+
+// Reopen closes the gzip stream and the file, then opens the file by the
+// filename again with a new gzip stream, e.g. after the file is renamed by
+// the external tools like logrotate, so that the rotated file is a complete
+// gzip.
+func (a *GzipFileAppender) Reopen() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return errors.New("log: appender " + a.filename + " is closed")
+	}
+	err := a.close()
+	if e := a.reopen(); e != nil {
+		return e
+	}
+	return err
+}
+
+func (a *GzipFileAppender) reopen() error {
+	file, err := os.OpenFile(a.filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		println("appender open ", a.filename, "error: ", err.Error())
+		return err
+	}
+	a.file = file
+	a.zw.Reset(file)
+	return nil
+}
+
+// close writes the trailer of the gzip stream and closes the file.
+func (a *GzipFileAppender) close() error {
+	if a.file == nil { // the reopen failed
+		return nil
+	}
+	err := a.zw.Close()
+	if e := a.file.Close(); err == nil {
+		err = e
+	}
+	a.file, a.dirty = nil, false
+	return err
+}
+
+// Close closes the gzip stream and the file, the subsequent logs are
+// dropped.
+func (a *GzipFileAppender) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	err := a.close()
+	a.mu.Unlock()
+	close(a.stop)
+	<-a.done
+	return err
+}
@@ -0,0 +1,90 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGzipFileAppender(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		filename = filepath.Join(t.TempDir(), "logs", "a.log.gz")
+	)
+
+	read := func(filename string) (string, error) {
+		f, err := os.Open(filename)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return "", err
+		}
+		data, err := ioutil.ReadAll(zr)
+		return string(data), err
+	}
+
+	app, err := NewGzipFileAppender(filename)
+	if !assert.NoError(err) {
+		return
+	}
+	app.Output(INFO, time.Now(), []byte("a\n"))
+	app.Output(INFO, time.Now(), []byte("b\n"))
+
+	// the flushed logs are readable before the stream is closed
+	assert.NoError(app.Flush())
+	data, err := read(filename)
+	assert.Equal(io.ErrUnexpectedEOF, err)
+	assert.Equal("a\nb\n", data)
+
+	// the renamed file is completed by the reopen
+	assert.NoError(os.Rename(filename, filename+".1"))
+	app.Output(INFO, time.Now(), []byte("c\n"))
+	assert.NoError(app.Reopen())
+	app.Output(INFO, time.Now(), []byte("d\n"))
+	assert.NoError(app.Close())
+	assert.Error(app.TryOutput(INFO, time.Now(), []byte("dropped\n")))
+
+	data, err = read(filename + ".1")
+	assert.NoError(err)
+	assert.Equal("a\nb\nc\n", data)
+	data, err = read(filename)
+	assert.NoError(err)
+	assert.Equal("d\n", data)
+
+	// the existing file is appended by a new gzip member
+	app, err = NewGzipFileAppender(filename)
+	if !assert.NoError(err) {
+		return
+	}
+	app.Output(INFO, time.Now(), []byte("e\n"))
+	assert.NoError(app.Close())
+	data, err = read(filename)
+	assert.NoError(err)
+	assert.Equal("d\ne\n", data)
+}
+
+func TestGzipFileAppenderFlushInterval(t *testing.T) {
+	defer func(d time.Duration) { GzipFlushInterval = d }(GzipFlushInterval)
+	GzipFlushInterval = 10 * time.Millisecond
+
+	filename := filepath.Join(t.TempDir(), "a.log.gz")
+	app, err := NewGzipFileAppender(filename)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer app.Close()
+	app.Output(INFO, time.Now(), []byte("a\n"))
+	assert.Eventually(t, func() bool {
+		info, err := os.Stat(filename)
+		return err == nil && info.Size() > 10 // more than the gzip header
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,308 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// HTTPRetries is the max number of the retries of posting a batch.
+	HTTPRetries = 3
+	// HTTPBackoff is the initial backoff between the retries of posting a
+	// batch, which is doubled for every retry.
+	HTTPBackoff = 100 * time.Millisecond
+)
+
+var gzippool = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
+// HTTPOption configures the HTTPAppender.
+type HTTPOption func(*HTTPAppender)
+
+// HTTPGzip decides whether or not to compress the body with gzip and set the
+// "Content-Encoding: gzip", it is disabled by default.
+func HTTPGzip(enable bool) HTTPOption {
+	return func(a *HTTPAppender) { a.gzip = enable }
+}
+
+// HTTPCaller decides whether or not to post the caller of the logs encoded
+// like JSONAppender, it is enabled by default. The Logger does not resolve
+// the caller for the appender when it is disabled. The appender returned by
+// NewLokiAppender never posts the caller.
+func HTTPCaller(enable bool) HTTPOption {
+	return func(a *HTTPAppender) { a.caller = enable }
+}
+
+// HTTPAppender is a RecordAppender which buffers the logs and POSTs them in
+// batches to an HTTP endpoint. A batch is posted when it has batchSize logs
+// or every flushInterval, the failed posting is retried with exponential
+// backoff, then the batch is dropped and counted, see Dropped and Err.
+type HTTPAppender struct {
+	dropped uint64 // keep 64-bit aligned for atomic operations
+	mu      sync.Mutex
+	url     string
+	client  *http.Client
+	batch   int
+	items   []httpitem
+	ctype   string
+	gzip    bool
+	caller  bool
+	encode  func(b []byte, r *Record) []byte
+	body    func(b []byte, items []httpitem) []byte
+	fault   atomic.Value
+	cl      sync.RWMutex // guards the lifetime of reqs
+	closed  bool
+	reqs    chan *httpreq
+	done    chan struct{}
+}
+
+type httpitem struct {
+	level Level
+	time  time.Time
+	data  []byte
+}
+
+type httpreq struct {
+	items []httpitem
+	err   error
+	done  chan struct{}
+}
+
+// NewHTTPAppender returns an HTTPAppender which posts the batches of logs to
+// url, the body is a JSON array of the logs encoded like JSONAppender.
+func NewHTTPAppender(url string, batchSize int, flushInterval time.Duration, opts ...HTTPOption) *HTTPAppender {
+	var a *HTTPAppender
+	a = newHTTPAppender(url, batchSize, flushInterval, opts, "application/json",
+		func(b []byte, r *Record) []byte {
+			var flags jsonflag
+			if !a.caller {
+				flags = jsonNoCaller
+			}
+			b = appendJSONRecord(b, r, flags)
+			return b[:len(b)-1] // trim the newline
+		},
+		func(b []byte, items []httpitem) []byte {
+			b = append(b, '[')
+			for i, item := range items {
+				if i != 0 {
+					b = append(b, ',')
+				}
+				b = append(b, item.data...)
+			}
+			return append(b, ']')
+		})
+	return a
+}
+
+func newHTTPAppender(url string, batchSize int, flushInterval time.Duration, opts []HTTPOption,
+	ctype string, encode func([]byte, *Record) []byte, body func([]byte, []httpitem) []byte) *HTTPAppender {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	a := &HTTPAppender{
+		url:    url,
+		client: &http.Client{Timeout: 30 * time.Second},
+		batch:  batchSize,
+		ctype:  ctype,
+		encode: encode,
+		body:   body,
+		caller: true,
+		reqs:   make(chan *httpreq, 64),
+		done:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	go a.loop(flushInterval)
+	return a
+}
+
+func (a *HTTPAppender) loop(interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	defer close(a.done)
+	for {
+		select {
+		case req, ok := <-a.reqs:
+			if !ok {
+				return
+			}
+			if len(req.items) != 0 {
+				req.err = a.post(req.items)
+			}
+			if req.done != nil {
+				close(req.done)
+			}
+		case <-tick:
+			a.mu.Lock()
+			items := a.take()
+			a.mu.Unlock()
+			if len(items) != 0 {
+				a.post(items)
+			}
+		}
+	}
+}
+
+func (a *HTTPAppender) take() []httpitem {
+	items := a.items
+	a.items = nil
+	return items
+}
+
+func (a *HTTPAppender) post(items []httpitem) (err error) {
+	body := a.body(nil, items)
+	if a.gzip {
+		if body, err = compress(body); err != nil {
+			atomic.AddUint64(&a.dropped, uint64(len(items)))
+			a.fault.Store(struct{ error }{err})
+			return err
+		}
+	}
+	backoff := HTTPBackoff
+	for i := 0; i <= HTTPRetries; i++ {
+		if i != 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		if err = a.do(body); err == nil {
+			return nil
+		}
+	}
+	atomic.AddUint64(&a.dropped, uint64(len(items)))
+	a.fault.Store(struct{ error }{err})
+	return err
+}
+
+func (a *HTTPAppender) do(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", a.ctype)
+	if a.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("log: http appender post %s: %s", a.url, resp.Status)
+	}
+	return nil
+}
+
+func compress(body []byte) ([]byte, error) {
+	var (
+		buf = bytes.NewBuffer(make([]byte, 0, len(body)/4))
+		w   = gzippool.Get().(*gzip.Writer)
+	)
+	defer gzippool.Put(w)
+	w.Reset(buf)
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (a *HTTPAppender) Output(level Level, t time.Time, data []byte) {
+	if n := len(data); n > 0 && data[n-1] == '\n' {
+		data = data[:n-1]
+	}
+	a.OutputRecord(&Record{Level: level, Time: t, Message: data, Data: data})
+}
+
+func (a *HTTPAppender) OutputRecord(r *Record) {
+	item := httpitem{level: r.Level, time: r.Time, data: a.encode(nil, r)}
+	a.mu.Lock()
+	a.items = append(a.items, item)
+	var items []httpitem
+	if len(a.items) >= a.batch {
+		items = a.take()
+	}
+	a.mu.Unlock()
+	if items == nil {
+		return
+	}
+
+	a.cl.RLock()
+	defer a.cl.RUnlock()
+	if a.closed {
+		atomic.AddUint64(&a.dropped, uint64(len(items)))
+		return
+	}
+	select {
+	case a.reqs <- &httpreq{items: items}:
+	default:
+		atomic.AddUint64(&a.dropped, uint64(len(items)))
+		a.fault.Store(struct{ error }{errors.New("log: http appender queue is full")})
+	}
+}
+
+// Flush posts the buffered logs and waits for the posting, it returns the
+// error of the posting.
+func (a *HTTPAppender) Flush() error {
+	a.mu.Lock()
+	items := a.take()
+	a.mu.Unlock()
+
+	a.cl.RLock()
+	if a.closed {
+		a.cl.RUnlock()
+		atomic.AddUint64(&a.dropped, uint64(len(items)))
+		return errors.New("log: http appender is closed")
+	}
+	req := &httpreq{items: items, done: make(chan struct{})}
+	a.reqs <- req
+	a.cl.RUnlock()
+	<-req.done
+	return req.err
+}
+
+// Close flushes the buffered logs and stops the background goroutine.
+func (a *HTTPAppender) Close() error {
+	err := a.Flush()
+	a.cl.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.reqs)
+	}
+	a.cl.Unlock()
+	<-a.done
+	return err
+}
+
+// NeedsCaller reports whether or not the caller is posted, see HTTPCaller.
+func (a *HTTPAppender) NeedsCaller() bool {
+	return a.caller
+}
+
+// Dropped returns the number of the logs dropped after the retries.
+func (a *HTTPAppender) Dropped() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
+
+// Err returns the last error which caused the logs dropped.
+func (a *HTTPAppender) Err() error {
+	err, _ := a.fault.Load().(struct{ error })
+	return err.error
+}
@@ -0,0 +1,134 @@
+package log
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type httprecorder struct {
+	mu      sync.Mutex
+	fails   int
+	batches [][]map[string]interface{}
+	ctypes  []string
+	cencs   []string
+}
+
+func (h *httprecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.fails > 0 {
+		h.fails--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var (
+		batch []map[string]interface{}
+		body  io.Reader = r.Body
+	)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+	b, _ := ioutil.ReadAll(body)
+	if err := json.Unmarshal(b, &batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	h.batches = append(h.batches, batch)
+	h.ctypes = append(h.ctypes, r.Header.Get("Content-Type"))
+	h.cencs = append(h.cencs, r.Header.Get("Content-Encoding"))
+}
+
+func TestHTTPAppender(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		h      = &httprecorder{fails: 1}
+		server = httptest.NewServer(h)
+		app    = NewHTTPAppender(server.URL, 2, time.Hour)
+		lg     = newTestLogger("http")
+	)
+	defer server.Close()
+
+	backoff := HTTPBackoff
+	HTTPBackoff = time.Millisecond
+	defer func() { HTTPBackoff = backoff }()
+
+	lg.SetAppender(app)
+	lg.Info("a")
+	lg.WithFields(Field{"k", "v"}).Warn("b")
+	lg.Error("c")
+	assert.Nil(app.Flush())
+
+	h.mu.Lock()
+	if assert.Equal(2, len(h.batches)) {
+		assert.Equal(2, len(h.batches[0]))
+		assert.Equal("a", h.batches[0][0]["message"])
+		assert.Equal("b", h.batches[0][1]["message"])
+		assert.Equal("v", h.batches[0][1]["k"])
+		assert.Equal(1, len(h.batches[1]))
+		assert.Equal("c", h.batches[1][0]["message"])
+		assert.Equal([]string{"application/json", "application/json"}, h.ctypes)
+		assert.Equal([]string{"", ""}, h.cencs)
+	}
+	h.fails = HTTPRetries + 1
+	h.mu.Unlock()
+
+	lg.Info("d")
+	assert.NotNil(app.Flush())
+	assert.Equal(uint64(1), app.Dropped())
+	assert.NotNil(app.Err())
+	assert.Nil(app.Close())
+}
+
+func TestHTTPAppenderInterval(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		h      = &httprecorder{}
+		server = httptest.NewServer(h)
+		app    = NewHTTPAppender(server.URL, 100, 10*time.Millisecond)
+	)
+	defer server.Close()
+	defer app.Close()
+
+	app.Output(INFO, time.Now(), []byte("a\n"))
+	assert.Eventually(func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return len(h.batches) == 1 && h.batches[0][0]["message"] == "a"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestHTTPAppenderGzip(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		h      = &httprecorder{}
+		server = httptest.NewServer(h)
+		app    = NewHTTPAppender(server.URL, 100, time.Hour, HTTPGzip(true))
+	)
+	defer server.Close()
+	defer app.Close()
+
+	for i := 0; i < 3; i++ {
+		app.Output(INFO, time.Now(), []byte("gzip\n"))
+		assert.Nil(app.Flush())
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	assert.Equal([]string{"gzip", "gzip", "gzip"}, h.cencs)
+	if assert.Equal(3, len(h.batches)) {
+		assert.Equal("gzip", h.batches[2][0]["message"])
+	}
+}
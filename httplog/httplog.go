@@ -0,0 +1,64 @@
+// Package httplog provides the helpers of logging the HTTP requests with the
+// structured fields of the logger, it keeps the net/http integration out of
+// the core package.
+package httplog
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/lrita/log"
+)
+
+// HTTPFields returns the common fields of the request:
+//
+//	method       the method of the request, e.g. "GET"
+//	path         the path of the URL
+//	remote_addr  the network address of the client, it is empty for the
+//	             requests sent by the client
+//	user_agent   the User-Agent header
+func HTTPFields(r *http.Request) []log.Field {
+	path := ""
+	if r.URL != nil {
+		path = r.URL.Path
+	}
+	return []log.Field{
+		log.F("method", r.Method),
+		log.F("path", path),
+		log.F("remote_addr", r.RemoteAddr),
+		log.F("user_agent", r.UserAgent()),
+	}
+}
+
+// roundtripper logs every round trip of the underlying RoundTripper.
+type roundtripper struct {
+	lg   log.Logger
+	next http.RoundTripper
+}
+
+// LogRoundTrip returns an http.RoundTripper which logs every round trip of
+// next to lg, the http.DefaultTransport is used if next is nil. The
+// successful round trip is logged at INFO like:
+//
+//	http round trip latency=1.2ms method=GET path=/ping remote_addr="" status=200 user_agent=curl/8.0
+//
+// the failed one is logged at ERROR with the error instead of the status.
+func LogRoundTrip(lg log.Logger, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &roundtripper{lg: lg, next: next}
+}
+
+func (t *roundtripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	begin := time.Now()
+	resp, err := t.next.RoundTrip(r)
+	fields := append(HTTPFields(r), log.Duration("latency", time.Since(begin)))
+	if err != nil {
+		t.lg.WithError(err).ErrorKV("http round trip", fields...)
+		return resp, err
+	}
+	fields = append(fields, log.F("status", resp.StatusCode))
+	t.lg.InfoKV("http round trip", fields...)
+	return resp, nil
+}
@@ -0,0 +1,72 @@
+package httplog
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lrita/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type dap struct {
+	l log.Level
+	d string
+}
+
+func (d *dap) Output(level log.Level, t time.Time, data []byte) {
+	d.l = level
+	d.d = string(data)
+}
+
+func TestHTTPFields(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "http://example.com/api/v1?q=1", nil)
+	r.Header.Set("User-Agent", "test/1.0")
+	assert.Equal(t, []log.Field{
+		{Key: "method", Value: "POST"},
+		{Key: "path", Value: "/api/v1"},
+		{Key: "remote_addr", Value: "192.0.2.1:1234"},
+		{Key: "user_agent", Value: "test/1.0"},
+	}, HTTPFields(r))
+}
+
+type rtfunc func(*http.Request) (*http.Response, error)
+
+func (f rtfunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestLogRoundTrip(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = log.New("httplog")
+	)
+
+	lg.SetFormat("[%l] %m")
+	lg.SetAppender(d)
+	rt := LogRoundTrip(lg, rtfunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path == "/fail" {
+			return nil, errors.New("refused")
+		}
+		return &http.Response{StatusCode: http.StatusTeapot}, nil
+	}))
+
+	r, _ := http.NewRequest(http.MethodGet, "http://example.com/ping", nil)
+	r.Header.Set("User-Agent", "test/1.0")
+	resp, err := rt.RoundTrip(r)
+	assert.NoError(err)
+	assert.Equal(http.StatusTeapot, resp.StatusCode)
+	assert.Equal(log.INFO, d.l)
+	assert.True(strings.HasPrefix(d.d, "[INFO] http round trip latency="), d.d)
+	assert.True(strings.HasSuffix(d.d, ` method=GET path=/ping remote_addr="" status=418 user_agent=test/1.0`+"\n"), d.d)
+
+	r, _ = http.NewRequest(http.MethodGet, "http://example.com/fail", nil)
+	_, err = rt.RoundTrip(r)
+	assert.EqualError(err, "refused")
+	assert.Equal(log.ERROR, d.l)
+	assert.Contains(d.d, " error=refused ")
+	assert.Contains(d.d, " path=/fail ")
+	assert.NotContains(d.d, "status=")
+}
@@ -0,0 +1,164 @@
+//go:build linux
+// +build linux
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// JournaldSocket is the socket of the journald native protocol.
+var JournaldSocket = "/run/systemd/journal/socket"
+
+// JournaldAppender is a RecordAppender which sends the logs to journald by
+// the native protocol. A log is sent as a datagram of the journal fields:
+//
+//	PRIORITY           the syslog severity of the log-level, FATAL is 2 (crit)
+//	                   and DEBUG/TRACE are 7 (debug)
+//	MESSAGE            the formatted data of the log without the newline
+//	SYSLOG_IDENTIFIER  the base name of the program
+//	CODE_FILE          the caller if the log-level resolves the caller
+//	CODE_LINE          the line of the caller
+//
+// followed by the fields of the log. The keys of the fields are converted to
+// the journal field names, which are uppercased and the characters other
+// than A-Z, 0-9 and '_' are replaced by '_', the leading '_' are removed,
+// a leading digit is prefixed by 'F', and the names are truncated to 64
+// characters. The log larger than the max datagram size is dropped.
+type JournaldAppender struct {
+	mu    sync.Mutex
+	conn  *net.UnixConn
+	ident string
+}
+
+// NewJournaldAppender returns a JournaldAppender which sends the logs to
+// JournaldSocket.
+func NewJournaldAppender() (*JournaldAppender, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: JournaldSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &JournaldAppender{conn: conn, ident: filepath.Base(os.Args[0])}, nil
+}
+
+func (a *JournaldAppender) Output(level Level, t time.Time, data []byte) {
+	a.OutputRecord(&Record{Level: level, Time: t, Data: data})
+}
+
+func (a *JournaldAppender) OutputRecord(r *Record) {
+	b := appendJournalRecord(getbuf(), a.ident, r)
+	a.mu.Lock()
+	_, err := a.conn.Write(b)
+	a.mu.Unlock()
+	putbuf(b)
+	if err != nil {
+		println("journald appender write error: ", err.Error())
+	}
+}
+
+// Close closes the connection to journald.
+func (a *JournaldAppender) Close() error {
+	return a.conn.Close()
+}
+
+var journalPriorities = map[Level]byte{
+	FATAL: '2',
+	ERROR: '3',
+	WARN:  '4',
+	INFO:  '6',
+	DEBUG: '7',
+	TRACE: '7',
+}
+
+// appendJournalRecord appends the record encoded in the journald native
+// protocol to b.
+func appendJournalRecord(b []byte, ident string, r *Record) []byte {
+	data := r.Data
+	if n := len(data); n > 0 && data[n-1] == '\n' {
+		data = data[:n-1]
+	}
+	b = append(b, "PRIORITY="...)
+	b = append(b, journalPriorities[r.Level], '\n')
+	b = appendJournalField(b, "MESSAGE", data)
+	b = appendJournalField(b, "SYSLOG_IDENTIFIER", []byte(ident))
+	if r.Caller != "" {
+		b = appendJournalField(b, "CODE_FILE", []byte(r.Caller))
+		b = append(b, "CODE_LINE="...)
+		b = strconv.AppendInt(b, int64(r.Line), 10)
+		b = append(b, '\n')
+	}
+	for i := range r.Fields {
+		n := len(b)
+		b = appendJournalName(b, r.Fields[i].Key)
+		if len(b) == n {
+			continue
+		}
+		// format the value after the name, then move it to the right place.
+		b = append(b, '=')
+		v := len(b)
+		b = appendValue(b, r.Fields[i].Value)
+		if bytes.IndexByte(b[v:], '\n') >= 0 {
+			value := append([]byte(nil), b[v:]...)
+			b = appendJournalValue(b[:v-1], value)
+		} else {
+			b = append(b, '\n')
+		}
+	}
+	return b
+}
+
+// appendJournalField appends the field to b, the value containing newlines
+// is encoded in the binary form.
+func appendJournalField(b []byte, name string, value []byte) []byte {
+	b = append(b, name...)
+	if bytes.IndexByte(value, '\n') < 0 {
+		b = append(b, '=')
+		b = append(b, value...)
+		return append(b, '\n')
+	}
+	return appendJournalValue(b, value)
+}
+
+// appendJournalValue appends the binary form of the value, which is a
+// newline, the 64-bit little endian length and the value followed by a
+// newline.
+func appendJournalValue(b []byte, value []byte) []byte {
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	b = append(b, '\n')
+	b = append(b, size[:]...)
+	b = append(b, value...)
+	return append(b, '\n')
+}
+
+// appendJournalName appends the key converted to the journal field name to
+// b, nothing is appended if there is no valid character in key.
+func appendJournalName(b []byte, key string) []byte {
+	n := len(b)
+	for i := 0; i < len(key) && len(b)-n < 64; i++ {
+		c := key[i]
+		switch {
+		case 'a' <= c && c <= 'z':
+			c -= 'a' - 'A'
+		case 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		default:
+			c = '_'
+		}
+		if len(b) == n {
+			if c == '_' {
+				continue
+			} else if '0' <= c && c <= '9' {
+				b = append(b, 'F')
+			}
+		}
+		b = append(b, c)
+	}
+	return b
+}
@@ -0,0 +1,85 @@
+//go:build linux
+// +build linux
+
+package log
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendJournalName(t *testing.T) {
+	assert := assert.New(t)
+	for key, name := range map[string]string{
+		"request_id":             "REQUEST_ID",
+		"Trace-ID":               "TRACE_ID",
+		"__private":              "PRIVATE",
+		"9lives":                 "F9LIVES",
+		"中":                      "",
+		strings.Repeat("k", 100): strings.Repeat("K", 64),
+	} {
+		assert.Equal(name, string(appendJournalName(nil, key)), key)
+	}
+}
+
+func TestAppendJournalRecord(t *testing.T) {
+	assert := assert.New(t)
+	r := &Record{
+		Level:  WARN,
+		Data:   []byte("[WARN] disk full\n"),
+		Caller: "/src/main.go",
+		Line:   42,
+		Fields: []Field{{"user", "alice"}, {"_", 1}, {"stack", "a\nb"}},
+	}
+	assert.Equal("PRIORITY=4\n"+
+		"MESSAGE=[WARN] disk full\n"+
+		"SYSLOG_IDENTIFIER=app\n"+
+		"CODE_FILE=/src/main.go\n"+
+		"CODE_LINE=42\n"+
+		"USER=alice\n"+
+		"STACK\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\n",
+		string(appendJournalRecord(nil, "app", r)))
+
+	r = &Record{Level: FATAL, Data: []byte("multi\nline\n")}
+	assert.Equal("PRIORITY=2\n"+
+		"MESSAGE\n\x0a\x00\x00\x00\x00\x00\x00\x00multi\nline\n"+
+		"SYSLOG_IDENTIFIER=app\n",
+		string(appendJournalRecord(nil, "app", r)))
+}
+
+func TestJournaldAppender(t *testing.T) {
+	assert := assert.New(t)
+	socket := filepath.Join(t.TempDir(), "journal.socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if !assert.NoError(err) {
+		return
+	}
+	defer conn.Close()
+
+	defer func(s string) { JournaldSocket = s }(JournaldSocket)
+	JournaldSocket = socket
+	a, err := NewJournaldAppender()
+	if !assert.NoError(err) {
+		return
+	}
+	defer a.Close()
+
+	lg := newTestLogger("journald")
+	lg.SetFormat("%m")
+	lg.SetAppender(a)
+	lg.SetCallerMinLevel(FATAL)
+	lg.WithFields(Field{"tenant", "t1"}).Info("hello")
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	assert.NoError(err)
+	msg := string(buf[:n])
+	assert.True(strings.HasPrefix(msg, "PRIORITY=6\nMESSAGE=hello tenant=t1\nSYSLOG_IDENTIFIER="), msg)
+	assert.True(strings.HasSuffix(msg, "\nTENANT=t1\n"), msg)
+}
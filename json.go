@@ -0,0 +1,311 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// JSONAppender is a RecordAppender which writes every log as a JSON object
+// in a single line to the underlying io.Writer, like:
+//
+//	{"time":"2006-01-02T15:04:05.999999999Z07:00","level":"INFO","caller":"main.go:10","message":"hello","key":"value"}
+//
+// The "caller" is omitted if the log-level does not resolve the caller, and
+// the fields of the log follow the "message" in the order of keys, see
+// SetFieldOrder. The caller is split into the "file", "line" and "func" by
+// SetCallerFields, and the numbers of the level are added by
+// SetLevelNumbers.
+type JSONAppender struct {
+	mu    sync.Mutex
+	w     io.Writer
+	flags jsonflag
+}
+
+// jsonflag is the bitmask of the optional outputs of the JSONAppender.
+type jsonflag uint8
+
+const (
+	jsonCallerFields jsonflag = 1 << iota // see SetCallerFields
+	jsonLevelNumbers                      // see SetLevelNumbers
+	jsonNoCaller                          // see SetCaller
+)
+
+// Severities are the normalized severities of the log-levels output by the
+// JSONAppender, see SetLevelNumbers. They are the severity numbers of
+// OpenTelemetry, the more severe log-level has the larger number.
+var Severities = map[Level]int{
+	TRACE: 1,
+	DEBUG: 5,
+	INFO:  9,
+	WARN:  13,
+	ERROR: 17,
+	FATAL: 21,
+}
+
+// NewJSONAppender returns a JSONAppender which writes to w.
+func NewJSONAppender(w io.Writer) *JSONAppender {
+	return &JSONAppender{w: w}
+}
+
+// Output writes the formatted data as the message of the JSON object, it is
+// only used when the appender is not invoked by the Logger.
+func (a *JSONAppender) Output(level Level, t time.Time, data []byte) {
+	if n := len(data); n > 0 && data[n-1] == '\n' {
+		data = data[:n-1]
+	}
+	a.OutputRecord(&Record{Level: level, Time: t, Message: data})
+}
+
+// SetCaller set whether or not to output the caller, it is enabled by
+// default. The Logger does not resolve the caller for the appender when it
+// is disabled, which saves the cost of `runtime.Caller` for every log.
+func (a *JSONAppender) SetCaller(enable bool) {
+	a.set(jsonNoCaller, !enable)
+}
+
+// NeedsCaller reports whether or not the caller is output, see SetCaller.
+func (a *JSONAppender) NeedsCaller() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.flags&jsonNoCaller == 0
+}
+
+// SetCallerFields set whether or not to output the caller as the separate
+// "file", "line" and "func" instead of the "caller", like:
+//
+//	"file":"main.go","line":10,"func":"main.main"
+//
+// It costs the resolving of the function of the caller for every log.
+func (a *JSONAppender) SetCallerFields(enable bool) {
+	a.set(jsonCallerFields, enable)
+}
+
+// SetLevelNumbers set whether or not to output the numbers of the level
+// following the "level", which are sortable for the log stores:
+//
+//	"level_num"  the value of the Level, the more severe log-level has the
+//	             smaller number, FATAL is 0 and TRACE is 5
+//	"severity"   the normalized severity in Severities, the more severe
+//	             log-level has the larger number, FATAL is 21 and TRACE is 1
+func (a *JSONAppender) SetLevelNumbers(enable bool) {
+	a.set(jsonLevelNumbers, enable)
+}
+
+func (a *JSONAppender) set(flag jsonflag, enable bool) {
+	a.mu.Lock()
+	if enable {
+		a.flags |= flag
+	} else {
+		a.flags &^= flag
+	}
+	a.mu.Unlock()
+}
+
+func (a *JSONAppender) OutputRecord(r *Record) {
+	a.mu.Lock()
+	flags := a.flags
+	a.mu.Unlock()
+	b := appendJSONRecord(getbuf(), r, flags)
+	a.mu.Lock()
+	a.w.Write(b)
+	a.mu.Unlock()
+	putbuf(b)
+}
+
+func (a *JSONAppender) Flush() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if f, ok := a.w.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// appendJSONRecord appends the record encoded as a JSON object followed by
+// a newline to b with the optional outputs of the flags.
+func appendJSONRecord(b []byte, r *Record, flags jsonflag) []byte {
+	b = append(b, `{"time":"`...)
+	b = r.Time.AppendFormat(b, time.RFC3339Nano)
+	b = append(b, `","level":"`...)
+	b = append(b, levelName(r.Level)...)
+	b = append(b, '"')
+	if flags&jsonLevelNumbers != 0 {
+		b = append(b, `,"level_num":`...)
+		b = strconv.AppendInt(b, int64(r.Level), 10)
+		b = append(b, `,"severity":`...)
+		b = strconv.AppendInt(b, int64(Severities[r.Level]), 10)
+	}
+	if flags&jsonNoCaller != 0 {
+		// the caller is omitted, see SetCaller
+	} else if r.Caller != "" && flags&jsonCallerFields != 0 {
+		b = append(b, `,"file":"`...)
+		b = appendJSONString(b, filepath.Base(r.Caller))
+		b = append(b, `","line":`...)
+		b = strconv.AppendInt(b, int64(r.Line), 10)
+		b = append(b, `,"func":"`...)
+		if fn := runtime.FuncForPC(r.PC); fn != nil {
+			b = appendJSONString(b, fn.Name())
+		}
+		b = append(b, '"')
+	} else if r.Caller != "" {
+		b = append(b, `,"caller":"`...)
+		b = appendJSONString(b, filepath.Base(r.Caller))
+		b = append(b, ':')
+		b = strconv.AppendInt(b, int64(r.Line), 10)
+		b = append(b, '"')
+	}
+	b = append(b, `,"message":"`...)
+	b = appendJSONString(b, b2s(r.Message))
+	b = append(b, '"')
+	if len(r.Fields) > 1 {
+		s := sortFields(r.Fields)
+		for _, i := range s.idx {
+			b = appendJSONField(b, &r.Fields[i])
+		}
+		s.release()
+	} else if len(r.Fields) == 1 {
+		b = appendJSONField(b, &r.Fields[0])
+	}
+	return append(b, '}', '\n')
+}
+
+func appendJSONField(b []byte, f *Field) []byte {
+	b = append(b, ',', '"')
+	b = appendJSONString(b, f.Key)
+	b = append(b, '"', ':')
+	return appendJSONValue(b, f.Value)
+}
+
+// appendJSONValue appends the value encoded as JSON to b.
+func appendJSONValue(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(b, "null"...)
+	case string:
+		return appendJSONQuote(b, v)
+	case []byte:
+		return appendJSONQuote(b, b2s(v))
+	case bool:
+		return strconv.AppendBool(b, v)
+	case int:
+		return strconv.AppendInt(b, int64(v), 10)
+	case int8:
+		return strconv.AppendInt(b, int64(v), 10)
+	case int16:
+		return strconv.AppendInt(b, int64(v), 10)
+	case int32:
+		return strconv.AppendInt(b, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(b, v, 10)
+	case uint:
+		return strconv.AppendUint(b, uint64(v), 10)
+	case uint8:
+		return strconv.AppendUint(b, uint64(v), 10)
+	case uint16:
+		return strconv.AppendUint(b, uint64(v), 10)
+	case uint32:
+		return strconv.AppendUint(b, uint64(v), 10)
+	case uint64:
+		return strconv.AppendUint(b, v, 10)
+	case float32:
+		return appendJSONFloat(b, float64(v), 32)
+	case float64:
+		return appendJSONFloat(b, v, 64)
+	case time.Time:
+		b = append(b, '"')
+		b = v.AppendFormat(b, time.RFC3339Nano)
+		return append(b, '"')
+	case time.Duration:
+		if DurationNumeric {
+			return strconv.AppendInt(b, int64(v), 10)
+		}
+		return appendJSONQuote(b, v.String())
+	case error:
+		return appendJSONQuote(b, errorString(v))
+	case fmt.Stringer:
+		return appendJSONQuote(b, stringerString(v))
+	case json.Marshaler:
+		if nilPointer(v) {
+			return append(b, "null"...)
+		}
+		if d, err := v.MarshalJSON(); err == nil {
+			return append(b, d...)
+		}
+	}
+	if d, err := json.Marshal(v); err == nil {
+		return append(b, d...)
+	}
+	return appendJSONQuote(b, fmt.Sprint(v))
+}
+
+func appendJSONFloat(b []byte, f float64, bits int) []byte {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		b = append(b, '"')
+		b = strconv.AppendFloat(b, f, 'g', -1, bits)
+		return append(b, '"')
+	}
+	return strconv.AppendFloat(b, f, 'g', -1, bits)
+}
+
+func appendJSONQuote(b []byte, s string) []byte {
+	b = append(b, '"')
+	b = appendJSONString(b, s)
+	return append(b, '"')
+}
+
+// b2s converts the slice of byte to string without copying, the string
+// must not be retained after the slice changed.
+func b2s(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+const hex = "0123456789abcdef"
+
+// appendJSONString appends the s escaped as the content of JSON string to b,
+// the invalid UTF-8 is replaced by U+FFFD.
+func appendJSONString(b []byte, s string) []byte {
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, `�`...)
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	return append(b, s[start:]...)
+}
@@ -0,0 +1,227 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONAppender(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		buf    = bytes.NewBuffer(nil)
+		lg     = newTestLogger("json")
+	)
+
+	lg.SetLevel(DEBUG)
+	lg.SetAppender(NewJSONAppender(buf))
+	lg.WithFields(
+		Field{"str", "a\"b\n"},
+		Field{"int", 1},
+		Field{"float", 1.5},
+		Field{"bool", true},
+		Field{"err", errors.New("failed")},
+		Field{"nil", nil},
+		Field{"map", map[string]int{"x": 1}},
+	).Infof("hello %s", "world")
+
+	var v map[string]interface{}
+	if !assert.Nil(json.Unmarshal(buf.Bytes(), &v), buf.String()) {
+		return
+	}
+	assert.Equal("INFO", v["level"])
+	assert.Equal("hello world", v["message"])
+	assert.Equal("a\"b\n", v["str"])
+	assert.Equal(float64(1), v["int"])
+	assert.Equal(1.5, v["float"])
+	assert.Equal(true, v["bool"])
+	assert.Equal("failed", v["err"])
+	assert.Nil(v["nil"])
+	assert.Equal(map[string]interface{}{"x": float64(1)}, v["map"])
+	assert.Regexp(`^json_test.go:\d+$`, v["caller"])
+	tm, _ := v["time"].(string)
+	_, err := time.Parse(time.RFC3339Nano, tm)
+	assert.Nil(err)
+}
+
+func TestJSONCallerFields(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		buf    = bytes.NewBuffer(nil)
+		lg     = newTestLogger("jsoncaller")
+		app    = NewJSONAppender(buf)
+		v      map[string]interface{}
+	)
+
+	lg.SetAppender(app)
+	app.SetCallerFields(true)
+	lg.Info("hello")
+
+	assert.Nil(json.Unmarshal(buf.Bytes(), &v), buf.String())
+	assert.Equal("json_test.go", v["file"])
+	assert.NotZero(v["line"])
+	assert.Equal("github.com/lrita/log.TestJSONCallerFields", v["func"])
+	assert.NotContains(v, "caller")
+
+	buf.Reset()
+	lg.SetCallerMinLevel(FATAL)
+	lg.Info("hello")
+	assert.NotContains(buf.String(), `"file"`)
+}
+
+func TestJSONSetCaller(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		buf    = bytes.NewBuffer(nil)
+		app    = NewJSONAppender(buf)
+		lg     = newTestLogger("jsonsetcaller")
+	)
+
+	lg.SetAppender(app)
+	assert.True(app.NeedsCaller())
+	lg.Info("a")
+	assert.Contains(buf.String(), `"caller":"json_test.go:`)
+
+	buf.Reset()
+	app.SetCaller(false)
+	assert.False(app.NeedsCaller())
+	lg.Info("b")
+	assert.NotContains(buf.String(), `"caller"`)
+	assert.Contains(buf.String(), `"message":"b"`)
+	assert.NotContains(string(appendJSONRecord(nil, &Record{Caller: "x.go", Line: 1}, jsonNoCaller)), "x.go")
+}
+
+func TestJSONLevelNumbers(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		buf    = bytes.NewBuffer(nil)
+		lg     = newTestLogger("jsonlevel")
+		app    = NewJSONAppender(buf)
+	)
+
+	lg.SetLevel(TRACE)
+	lg.SetAppender(app)
+	lg.SetCallerMinLevel(FATAL)
+	app.SetLevelNumbers(true)
+	for _, c := range []struct {
+		level    Level
+		severity int
+		logf     func(...interface{})
+	}{
+		{TRACE, 1, lg.Trace},
+		{DEBUG, 5, lg.Debug},
+		{INFO, 9, lg.Info},
+		{WARN, 13, lg.Warn},
+		{ERROR, 17, lg.Error},
+	} {
+		var v map[string]interface{}
+		buf.Reset()
+		c.logf("m")
+		assert.Nil(json.Unmarshal(buf.Bytes(), &v), buf.String())
+		assert.Equal(float64(c.level), v["level_num"], LevelsToString[c.level])
+		assert.Equal(float64(c.severity), v["severity"], LevelsToString[c.level])
+	}
+	assert.Contains(buf.String(), `"level":"ERROR","level_num":1,"severity":17,"message":"m"`)
+
+	buf.Reset()
+	app.SetLevelNumbers(false)
+	lg.Info("m")
+	assert.NotContains(buf.String(), "level_num")
+}
+
+func TestAppendJSONString(t *testing.T) {
+	assert := assert.New(t)
+	for _, s := range []string{"", "abc", "\"\\/", "\x00\x1f\t\r\n", "中文", "\xff"} {
+		var v string
+		b := appendJSONQuote(nil, s)
+		if assert.Nil(json.Unmarshal(b, &v), string(b)) {
+			assert.Equal(string(bytes.ToValidUTF8([]byte(s), []byte("�"))), v)
+		}
+	}
+	assert.Equal(`"NaN"`, string(appendJSONValue(nil, math.NaN())))
+}
+
+// digitMarshaler dereferences its receiver, which panics for the nil
+// pointer.
+type digitMarshaler struct {
+	d byte
+}
+
+func (m *digitMarshaler) MarshalJSON() ([]byte, error) { return []byte{'0' + m.d}, nil }
+
+func TestJSONNilPointerValues(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(`"<nil>"`, string(appendJSONValue(nil, (*fieldError)(nil))))
+	assert.Equal(`"%!v(PANIC=String method: boom)"`, string(appendJSONValue(nil, panicStringer{})))
+	assert.Equal(`null`, string(appendJSONValue(nil, (*digitMarshaler)(nil))))
+	assert.Equal(`7`, string(appendJSONValue(nil, &digitMarshaler{7})))
+
+	buf := bytes.NewBuffer(nil)
+	lg := newTestLogger("jsonnilpointer")
+	lg.SetAppender(NewJSONAppender(buf))
+	lg.WithError((*fieldError)(nil)).Error("failed")
+	assert.Contains(buf.String(), `"message":"failed","error":"<nil>"}`)
+}
+
+func TestJSONFieldOrder(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		r      = &Record{
+			Time:    time.Unix(0, 0).UTC(),
+			Level:   INFO,
+			Message: []byte("m"),
+			Fields:  []Field{{"c", 1}, {"a", 2}, {"b", 3}, {"a", 4}},
+		}
+	)
+	assert.Equal(`{"time":"1970-01-01T00:00:00Z","level":"INFO","message":"m","a":2,"a":4,"b":3,"c":1}`+"\n",
+		string(appendJSONRecord(nil, r, 0)))
+}
+
+var jsonbenchrecord = &Record{
+	Time:    time.Now(),
+	Level:   INFO,
+	Caller:  "/path/to/json_test.go",
+	Line:    10,
+	Message: []byte("benchmark json encoding"),
+	Fields: []Field{
+		{"request_id", "d7d6d59"},
+		{"user", "lrita"},
+		{"status", 200},
+		{"latency", 0.25},
+		{"cached", false},
+	},
+}
+
+func TestJSONEncodingAllocs(t *testing.T) {
+	b := make([]byte, 0, 1024)
+	allocs := testing.AllocsPerRun(100, func() {
+		b = appendJSONRecord(b[:0], jsonbenchrecord, 0)
+	})
+	assert.Equal(t, 0.0, allocs)
+}
+
+func BenchmarkJSONEncoding(b *testing.B) {
+	buf := make([]byte, 0, 1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf = appendJSONRecord(buf[:0], jsonbenchrecord, 0)
+	}
+}
+
+func BenchmarkJSONAppender(b *testing.B) {
+	app := NewJSONAppender(ioutil.Discard)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			app.OutputRecord(jsonbenchrecord)
+		}
+	})
+}
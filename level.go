@@ -1,5 +1,10 @@
 package log
 
+import (
+	"strings"
+	"sync/atomic"
+)
+
 type Level int8
 
 const (
@@ -28,3 +33,63 @@ var LevelsToString = map[Level]string{
 	ERROR: "ERROR",
 	FATAL: "FATAL",
 }
+
+// Levels returns all the log-levels in the order of severity, from the most
+// severe FATAL to the least severe TRACE, e.g. to iterate the log-levels in
+// the deterministic order instead of ranging over LevelsToString.
+func Levels() []Level {
+	return []Level{FATAL, ERROR, WARN, INFO, DEBUG, TRACE}
+}
+
+// levelnames is the names of the log-levels rendered by %l and the
+// JSONAppender, its actual type is map[Level]string, nil means
+// LevelsToString.
+var levelnames atomic.Value
+
+// SetLevelCase set whether or not to render the log-levels in lowercase like
+// "info", which is expected by some log stores, by %l of the format and the
+// "level" of the JSONAppender. The lowercase names are computed from
+// LevelsToString when it is called.
+func SetLevelCase(lower bool) {
+	var names map[Level]string
+	if lower {
+		names = make(map[Level]string, len(LevelsToString))
+		for level, name := range LevelsToString {
+			names[level] = strings.ToLower(name)
+		}
+	}
+	levelnames.Store(names)
+}
+
+// levelName returns the name of the level in the case set by SetLevelCase.
+func levelName(level Level) string {
+	if names, _ := levelnames.Load().(map[Level]string); names != nil {
+		return names[level]
+	}
+	return LevelsToString[level]
+}
+
+// MoreSevereThan reports whether l is more severe than o, e.g. FATAL is more
+// severe than ERROR. The severer level has the smaller value.
+func (l Level) MoreSevereThan(o Level) bool {
+	return l < o
+}
+
+// Enabled reports whether the log of level l is emitted by the logger whose
+// level is threshold, i.e. l is as severe as or more severe than threshold.
+func (l Level) Enabled(threshold Level) bool {
+	return l <= threshold
+}
+
+// LevelFromVerbosity maps the verbosity of the command line like -v, -vv to
+// the log-level: 0 is INFO, 1 is DEBUG, 2 and more are TRACE, -1 is WARN,
+// -2 and less are ERROR.
+func LevelFromVerbosity(v int) Level {
+	switch {
+	case v >= 2:
+		return TRACE
+	case v <= -2:
+		return ERROR
+	}
+	return INFO + Level(v)
+}
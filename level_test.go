@@ -0,0 +1,76 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelCompare(t *testing.T) {
+	assert := assert.New(t)
+	levels := Levels() // most severe first
+	for i, a := range levels {
+		for j, b := range levels {
+			assert.Equal(i < j, a.MoreSevereThan(b), "%s > %s", LevelsToString[a], LevelsToString[b])
+			assert.Equal(i <= j, a.Enabled(b), "%s enabled at %s", LevelsToString[a], LevelsToString[b])
+		}
+	}
+}
+
+func TestLevelFromVerbosity(t *testing.T) {
+	assert := assert.New(t)
+	for v, level := range map[int]Level{
+		-100: ERROR,
+		-3:   ERROR,
+		-2:   ERROR,
+		-1:   WARN,
+		0:    INFO,
+		1:    DEBUG,
+		2:    TRACE,
+		3:    TRACE,
+		100:  TRACE,
+	} {
+		assert.Equal(level, LevelFromVerbosity(v), "%d", v)
+	}
+}
+
+func TestLevels(t *testing.T) {
+	assert := assert.New(t)
+	levels := Levels()
+	assert.Equal([]Level{FATAL, ERROR, WARN, INFO, DEBUG, TRACE}, levels)
+	assert.Len(levels, len(LevelsToString))
+	for i := 1; i < len(levels); i++ {
+		assert.True(levels[i-1].MoreSevereThan(levels[i]))
+	}
+	levels[0] = TRACE
+	assert.Equal(FATAL, Levels()[0], "returns a new slice")
+}
+
+func TestSetLevelCase(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		b      bytes.Buffer
+		lg     = newTestLogger("levelcase")
+	)
+	defer SetLevelCase(false)
+
+	lg.SetFormat("[%l] %m")
+	lg.SetAppender(d)
+	lg.AddAppender(NewJSONAppender(&b), ERROR)
+	lg.Info("a")
+	assert.Equal("[INFO] a\n", d.d)
+
+	SetLevelCase(true)
+	lg.Info("b")
+	assert.Equal("[info] b\n", d.d)
+	lg.Error("c")
+	assert.Equal("[error] c\n", d.d)
+	assert.Contains(b.String(), `"level":"error"`)
+	assert.Equal(0.0, testing.AllocsPerRun(10, func() { _ = levelName(WARN) }))
+
+	SetLevelCase(false)
+	lg.Warn("d")
+	assert.Equal("[WARN] d\n", d.d)
+}
@@ -1,43 +1,177 @@
 package log
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	stdlog "log"
+	"math/rand"
 	"os"
 	"path/filepath"
-	"runtime"
+	"reflect"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 	"unsafe"
 
-	"github.com/lrita/cache"
 	"github.com/lrita/ratelimit"
 )
 
-// ExitOnFatal decides whether or not to exit when fatal log printing.
-var ExitOnFatal = true
+var (
+	// ExitOnFatal decides whether or not to exit when fatal log printing.
+	ExitOnFatal = true
+	// FatalExitCode is the exit code when fatal log printing, if the logger
+	// is not set its own by SetFatalExitCode.
+	FatalExitCode = 1
+	// RatelimitJitter is the max fraction of the rate adjusted randomly for
+	// the rate limit set by SetRatelimit, e.g. 0.1 makes the rate of
+	// SetRatelimit(100) be in [90, 110]. It avoids the instances in a fleet
+	// which have the same rate limit dropping logs in lockstep. It should be
+	// in [0, 1), default is 0, which disables the jitter.
+	RatelimitJitter float64
+
+	exit             = os.Exit
+	stderr io.Writer = os.Stderr
+
+	strictAppender uint32
+	printSpacing   uint32
+
+	jittermu sync.Mutex
+	jitter   = rand.New(rand.NewSource(time.Now().UnixNano() ^ int64(os.Getpid())))
+)
+
+// SetStrictAppender set whether or not to warn on the stderr when a logger
+// drops a log because its log-level has no appender, which is usually
+// caused by a misconfiguration like SetAppender(app, ERROR) on a logger
+// without appenders. Every logger warns at most once. It is disabled by
+// default, which drops the logs silently.
+func SetStrictAppender(enable bool) {
+	var v uint32
+	if enable {
+		v = 1
+	}
+	atomic.StoreUint32(&strictAppender, v)
+}
+
+// SetPrintSpacing set whether or not to always join the arguments of the
+// logs like Info("a", 1, "b") with a space like `fmt.Sprintln`, which
+// renders "a 1 b" instead of "a1b". It is disabled by default, which joins
+// them like `fmt.Sprint` that only adds the spaces between the operands
+// when neither is a string. The separator of SetFieldSeparator takes
+// precedence over it.
+func SetPrintSpacing(always bool) {
+	var v uint32
+	if always {
+		v = 1
+	}
+	atomic.StoreUint32(&printSpacing, v)
+}
 
 type Logger interface {
-	// New return a new log handler which inherit its appender and formater
+	// New return a new log handler which inherit its appender and formater,
+	// the same handler is returned for the same name. Note that reusing a
+	// name returns the shared logger which is already configured by the
+	// previous callers, use a unique name or Clone for an independent one.
 	New(name string) Logger
+	// Clone returns an independent logger of the same name which is seeded
+	// with the current configuration of the logger, it has no parent and
+	// does not receive the changes of the logger. The rate limits of the
+	// clone are refilled, so that it does not share them with the logger.
+	Clone() Logger
+	// WithFields return a log handler which attaches the fields to all
+	// the logs emitted by it. The fields are passed to the RecordAppender
+	// and appended to the %m in the format.
+	WithFields(fields ...Field) Logger
+	// WithError return a log handler which attaches the error as the field
+	// of ErrorKey, and the type of the error as the field of ErrorTypeKey if
+	// it is set. The nil error is not attached.
+	WithError(err error) Logger
+	// AtSite return a log handler which caches the caller in the site for
+	// the logs emitted by it, see CallSite.
+	AtSite(site *CallSite) Logger
 	// Level return the logger current log-level
 	Level() Level
 	// SetLevel set the logger current log-level
 	SetLevel(level Level)
+	// BindLevel makes the logger and its children read the log-level from
+	// the level by atomic.LoadInt32 for every log, so that the external
+	// config system can change the log-level by atomic.StoreInt32 without
+	// calling SetLevel, e.g. for the fleet. The nil level unbinds it, the
+	// SetLevel also unbinds it.
+	BindLevel(level *int32)
+	// WithLevel set the logger current log-level and returns the function
+	// restoring the previous one, e.g. `defer logger.WithLevel(TRACE)()`
+	// for a scope. The restoring also makes the logger inherit the later
+	// changes of the log-level of its parent again if it did before, the
+	// changes of the parent in the scope are not applied. The scopes should
+	// be nested, the overlapped scopes of the concurrent goroutines restore
+	// the log-level in the order they end, which may not be the original.
+	WithLevel(level Level) (restore func())
 	// SetAppender the given log-level to use the special appender.
-	// If non-given log-level, all log-level use it
+	// If non-given log-level, all log-level use it, which replaces all the
+	// appenders, prefer ReplaceAllAppenders to make it explicit.
 	SetAppender(appender Appender, levels ...Level)
-	// SetRatelimit the give limit(QPS) rate to the logger.
+	// AddAppender adds the appender to the given log-levels, the logs of
+	// which are output to the appender besides their current appenders.
+	// If non-given log-level, it is added to all log-levels.
+	AddAppender(appender Appender, levels ...Level)
+	// AddNamedAppender adds the appender like AddAppender and registers it
+	// by the name, so that it can be removed by RemoveAppender later, e.g.
+	// attaching a debug sink temporarily. The appender registered by the
+	// same name is removed first.
+	AddNamedAppender(name string, appender Appender, levels ...Level)
+	// RemoveAppender removes the appender registered by the name from all
+	// the log-levels, it does nothing if there is no such appender.
+	RemoveAppender(name string)
+	// ReplaceAllAppenders set all log-levels to use the appender only.
+	ReplaceAllAppenders(appender Appender)
+	// SwapAppender set the appender like SetAppender and returns the
+	// previous appenders of the log-levels, which can be restored by
+	// SetAppender(previous[level], level) for every level.
+	SwapAppender(appender Appender, levels ...Level) (previous map[Level]Appender)
+	// SetAppenderFunc set every log-level to use the appender returned by fn
+	// in one update, fn is called once for every log-level.
+	SetAppenderFunc(fn func(Level) Appender)
+	// SetOutput set all log-level to write to w, like the SetOutput of
+	// standard library.
+	SetOutput(w io.Writer)
+	// SetRatelimit the give limit(QPS) rate to the logger. Zero removes the
+	// rate limit.
 	SetRatelimit(limit int64, levels ...Level)
+	// SetRatelimitSample set the rate limit like SetRatelimit, but samples
+	// 1 in k of the logs over the limit instead of dropping all of them,
+	// which keeps a trickle of the logs in the bursts. The sampled out logs
+	// are counted as the dropped logs, see SetRatelimitNotice. The sampled
+	// logs carry the fields "sampled" of true and "dropped" of the number
+	// of the logs dropped since the last sampled one in the structured
+	// outputs, i.e. the RecordAppender and the binary format. The k not
+	// greater than 1 keeps every log, which removes the rate limit.
+	SetRatelimitSample(limit, k int64, levels ...Level)
+	// SetRatelimitFloor guarantees at least n logs per second of each of the
+	// given log-levels pass through the rate limit, e.g. the heartbeats at
+	// INFO are not starved by the flood of DEBUG sharing the rate limit.
+	// Zero removes the floor, which is the default.
+	SetRatelimitFloor(n int64, levels ...Level)
+	// SetRatelimitNotice emits a WARN log like "rate limit dropped 10
+	// messages at INFO" every interval for every log-level of the logger
+	// and its children which dropped the logs by the rate limit in the
+	// interval, the notices are not rate limited. Zero stops the notices,
+	// which is the default.
+	SetRatelimitNotice(interval time.Duration)
 	// SetFormat the given log-level to use the special format.
 	// If non-given log-level, all log-level use it
 	// fmt is a pattern-string, default is "%F %T [%l] %m"
 	// %m => the log message and its arguments formatted with `fmt.Sprintf` or `fmt.Sprint`
-	// %l => the log-level string
-	// %C => the caller with full file path
+	// %l => the log-level string, see SetLevelCase
+	// %C => the caller with full file path, or the path relative to the
+	//       source root, see SetSourceRoot
 	// %c => the caller with short file path
 	// %L => the line number of caller
-	// %% => '%'
+	// %% => '%', the trailing '%' is also output as '%'
 	// %n => '\n'
 	// %F => the date formatted like "2006-01-02"
 	// %D => the date formatted like "01/02/06"
@@ -47,11 +181,81 @@ type Logger interface {
 	// %b => the short name of month like "Jan"
 	// %B => the full name of month like "January"
 	// %d => the datetime formatted like RFC3339 "2006-01-02T15:04:05Z07:00"
+	// %i => the sequence number of the emitted logs of the logger, starts from 1
+	// %h => the hostname of the machine
+	// %e => the elapsed time since the previous log of the logger rendering
+	//       %e like "1.5s", the first log outputs '-'
+	// %S => the static fields set by SetStaticFields like "env=prod region=us"
 	SetFormat(fmt string, levels ...Level)
+	// SetFormatChecked set the format like SetFormat, but returns the error
+	// without setting it if the format has any unknown verb or the trailing
+	// '%', which are skipped or output as is by SetFormat, so that the
+	// mistakes of the config are found at the startup.
+	SetFormatChecked(fmt string, levels ...Level) error
+	// SetBinaryFormat set the given log-levels to encode the logs in the
+	// compact binary records instead of the text, see BinaryLogReader.
+	SetBinaryFormat(levels ...Level)
 	// SetCallDepth set callee stack depth
 	SetCallDepth(d int)
+	// SetCallerMinLevel set the least severe log-level which resolves the
+	// caller for %C/%c/%L, the less severe log-levels output '-' instead.
+	// Default is TRACE, which means all log-levels resolve the caller.
+	SetCallerMinLevel(level Level)
+	// SetFatalExitCode set the exit code when fatal log printing, which
+	// overrides the FatalExitCode.
+	SetFatalExitCode(code int)
+	// SetIndentContinuation set whether or not to indent the continuation
+	// lines of a multi-line message with the width of the prefix before %m,
+	// so that the messages like stack traces are aligned.
+	SetIndentContinuation(enable bool)
+	// SetFieldSeparator set the separator joining the arguments of the logs
+	// like Info("a", "b"), which are joined like `fmt.Sprint` by default.
+	// It is not used by the logs like Infof.
+	SetFieldSeparator(sep string)
+	// SetErrorChain set whether or not to render the error arguments of the
+	// logs with their wrapped errors, which are unwrapped by `errors.Unwrap`
+	// and appended like "err: wrapped" unless the message of the error
+	// already includes them. The errors implementing fmt.Formatter are not
+	// changed for the logs like Infof. It is disabled by default.
+	SetErrorChain(enable bool)
+	// SetLevelDecoration set the literals wrapping the %m of the log-level,
+	// e.g. SetLevelDecoration(ERROR, ">>> ", " <<<"). The empty prefix and
+	// suffix remove the decoration.
+	SetLevelDecoration(level Level, prefix, suffix string)
 	// IsDebugEnabled indicates whether debug level is enabled
 	IsDebugEnabled() bool
+	// WouldLog indicates whether a log of the given log-level would be
+	// emitted now, it checks the log-level, the appender and the rate limit
+	// with its floor and sampling without consuming the rate limit.
+	WouldLog(level Level) bool
+	// LogConfig emits an INFO log describing the current log-level, formats
+	// appenders and rate limits of the logger, see Describe.
+	LogConfig()
+	// Recover logs the panic in progress with the stack trace at ERROR and
+	// panics again, it must be called directly by defer like
+	// `defer logger.Recover()`.
+	Recover()
+	// RecoverAndContinue logs the panic in progress like Recover but stops
+	// the panicking, so that the function returns normally.
+	RecoverAndContinue()
+	// Configure applies the whole configuration to the logger at once, so
+	// that the concurrent logs never observe a half-applied configuration.
+	// It is the same as setting every attribute by its setter, the
+	// children inherit the attributes which they have not set by
+	// themselves.
+	Configure(c Config)
+	// Describe returns a human-readable summary of the current log-level,
+	// formats, appenders and rate limits of the logger, which helps to
+	// find out what the logger inherits from its parent.
+	Describe() string
+	// StdlibAdapter returns a logger of standard library, every line written
+	// by which is emitted as a log at the given log-level. The returned
+	// logger has no prefix and flags to avoid double timestamps.
+	StdlibAdapter(level Level) *stdlog.Logger
+	// LevelWriter returns an io.Writer, every line written to which is
+	// emitted as a log at the given log-level, e.g. the writers of the
+	// frameworks like gin.DefaultErrorWriter = logger.LevelWriter(ERROR).
+	LevelWriter(level Level) io.Writer
 
 	Fatal(v ...interface{})
 	Error(v ...interface{})
@@ -61,18 +265,55 @@ type Logger interface {
 	Trace(v ...interface{})
 
 	Fatalf(fmt string, v ...interface{})
+	// FatalWithCode logs like Fatal and exits with the code, which
+	// overrides the exit code of SetFatalExitCode for the single log.
+	FatalWithCode(code int, v ...interface{})
 	Errorf(fmt string, v ...interface{})
 	Infof(fmt string, v ...interface{})
 	Warnf(fmt string, v ...interface{})
 	Debugf(fmt string, v ...interface{})
 	Tracef(fmt string, v ...interface{})
+
+	// The XxxKV attach the fields to the single log without deriving a
+	// log handler like WithFields, e.g. InfoKV("done", F("took", d)).
+	FatalKV(msg string, kv ...Field)
+	ErrorKV(msg string, kv ...Field)
+	InfoKV(msg string, kv ...Field)
+	WarnKV(msg string, kv ...Field)
+	DebugKV(msg string, kv ...Field)
+	TraceKV(msg string, kv ...Field)
+
+	// The XxxAt log with the time t instead of the current time, e.g. for
+	// replaying or importing the events with their original time. The time
+	// t only affects the formatted timestamp of the log and the Time of the
+	// Record, the Appender.Output is passed the current time, so that the
+	// RotateAppender writes the log to the current file.
+	FatalAt(t time.Time, v ...interface{})
+	ErrorAt(t time.Time, v ...interface{})
+	InfoAt(t time.Time, v ...interface{})
+	WarnAt(t time.Time, v ...interface{})
+	DebugAt(t time.Time, v ...interface{})
+	TraceAt(t time.Time, v ...interface{})
 }
 
+// logger publishes its configuration by swapping the meta atomically, so
+// the logging never blocks. The mutex l serializes the writers of meta and
+// guards the children, it is only held while swapping the meta and taking a
+// snapshot of the children, the propagation to the children is done after
+// releasing it. The propagations are serialized by the mutex tree shared by
+// the loggers of a tree, so that the children always end up with the
+// configuration which their parent ends up with.
 type logger struct {
+	seq      uint64    // keep 64-bit aligned for atomic operations
+	last     int64     // the unix nanoseconds of the previous log rendering %e
+	drops    [8]uint64 // the logs dropped by the rate limit of the log-levels
+	warned   uint32    // the missing appender is warned, see SetStrictAppender
 	l        sync.Mutex
 	name     string
 	meta     unsafe.Pointer
 	children []*logger
+	notice   chan struct{} // stops the notices, see SetRatelimitNotice
+	tree     *sync.Mutex   // serializes the propagations of the tree
 }
 
 const (
@@ -80,55 +321,230 @@ const (
 	detachapp
 	detachfmt
 	detachlmt
+	detachclr
+	detachext
+	detachind
+	detachsep
+	detachdec
+	detacherr
+
+	detachall = 1<<iota - 1
 )
 
 type meta struct {
-	detach    uint8
+	detach    uint16
 	level     Level
+	bound     *int32 // overrides the level, see BindLevel
 	calldepth int
+	callerlvl Level
+	exitcode  *int
+	indent    bool
+	sep       string
+	errchain  bool
+	decos     map[Level]decoration // never modified after stored
 	appenders map[Level]Appender
-	formats   map[Level]string
+	named     map[string]Appender // never modified after stored
+	formats   map[Level]*layout
 	limits    map[Level]*ratelimit.Bucket
+	samples   map[Level]*sampler
+	floors    map[Level]*floor
+}
+
+// sampler lets 1 in k of the logs over the rate limit through, see
+// SetRatelimitSample.
+type sampler struct {
+	n       uint64 // the number of the logs over the rate limit
+	dropped uint64 // the number of the logs dropped since the last sampled
+	k       uint64
+}
+
+// take reports whether the log over the rate limit is sampled, and returns
+// the number of the logs dropped since the last sampled one. It is false
+// for the nil sampler.
+func (s *sampler) take() (bool, uint64) {
+	if s == nil {
+		return false, 0
+	}
+	if atomic.AddUint64(&s.n, 1)%s.k == 0 {
+		return true, atomic.SwapUint64(&s.dropped, 0)
+	}
+	atomic.AddUint64(&s.dropped, 1)
+	return false, 0
+}
+
+// peek reports whether the next log over the rate limit would be sampled
+// without taking it.
+func (s *sampler) peek() bool {
+	return s != nil && (atomic.LoadUint64(&s.n)+1)%s.k == 0
+}
+
+// threshold returns the log-level of the logger, which is read from the
+// bound level if any.
+func (m *meta) threshold() Level {
+	if m.bound != nil {
+		return Level(atomic.LoadInt32(m.bound))
+	}
+	return m.level
+}
+
+// floor lets the first n logs of every second through over the rate limit,
+// see SetRatelimitFloor.
+type floor struct {
+	state uint64 // the second in the high 32 bits and the count of it
+	n     uint64
+}
+
+// take reports whether the log over the rate limit is in the floor of the
+// current second, it is false for the nil floor.
+func (f *floor) take() bool {
+	if f == nil {
+		return false
+	}
+	sec := uint64(uint32(time.Now().Unix()))
+	for {
+		old := atomic.LoadUint64(&f.state)
+		count := old & 0xffffffff
+		if old>>32 != sec {
+			count = 0
+		}
+		if count >= f.n {
+			return false
+		}
+		if atomic.CompareAndSwapUint64(&f.state, old, sec<<32|(count+1)) {
+			return true
+		}
+	}
+}
+
+// peek reports whether the log over the rate limit would be in the floor of
+// the current second without taking it.
+func (f *floor) peek() bool {
+	if f == nil {
+		return false
+	}
+	state := atomic.LoadUint64(&f.state)
+	return state>>32 != uint64(uint32(time.Now().Unix())) || state&0xffffffff < f.n
+}
+
+// decoration is the literals wrapping the %m of a log-level.
+type decoration struct {
+	prefix string
+	suffix string
 }
 
 func (m *meta) clone() *meta {
 	mm := &meta{
 		detach:    m.detach,
 		level:     m.level,
+		bound:     m.bound,
 		calldepth: m.calldepth,
+		callerlvl: m.callerlvl,
+		exitcode:  m.exitcode,
+		indent:    m.indent,
+		sep:       m.sep,
+		errchain:  m.errchain,
+		decos:     m.decos,
 		appenders: make(map[Level]Appender),
-		formats:   make(map[Level]string),
+		named:     m.named,
+		formats:   make(map[Level]*layout),
 		limits:    make(map[Level]*ratelimit.Bucket),
 	}
 	for level, app := range m.appenders {
 		mm.appenders[level] = app
 	}
-	for level, fmt := range m.formats {
-		mm.formats[level] = fmt
+	for level, f := range m.formats {
+		mm.formats[level] = f
 	}
 	for level, l := range m.limits {
 		mm.limits[level] = l
 	}
+	if len(m.samples) != 0 {
+		mm.samples = make(map[Level]*sampler, len(m.samples))
+		for level, s := range m.samples {
+			mm.samples[level] = s
+		}
+	}
+	if len(m.floors) != 0 {
+		mm.floors = make(map[Level]*floor, len(m.floors))
+		for level, f := range m.floors {
+			mm.floors[level] = f
+		}
+	}
+	return mm
+}
+
+// fork returns the clone of the meta which has its own states of the rate
+// limits, the sharing between the log-levels is kept, see Logger.Clone.
+func (m *meta) fork() *meta {
+	var (
+		mm       = m.clone()
+		buckets  = make(map[*ratelimit.Bucket]*ratelimit.Bucket)
+		samplers = make(map[*sampler]*sampler)
+		floors   = make(map[*floor]*floor)
+	)
+	for level, b := range mm.limits {
+		if _, ok := buckets[b]; !ok {
+			buckets[b] = ratelimit.NewBucketWithRate(b.Rate(), b.Capacity())
+		}
+		mm.limits[level] = buckets[b]
+	}
+	for level, s := range mm.samples {
+		if _, ok := samplers[s]; !ok {
+			samplers[s] = &sampler{k: s.k}
+		}
+		mm.samples[level] = samplers[s]
+	}
+	for level, f := range mm.floors {
+		if _, ok := floors[f]; !ok {
+			floors[f] = &floor{n: f.n}
+		}
+		mm.floors[level] = floors[f]
+	}
 	return mm
 }
 
+// flush flushes the distinct appenders of all log-levels which implement
+// Flusher, so that the buffered logs are not lost when exiting.
+func (m *meta) flush() {
+	flushed := make([]Flusher, 0, len(m.appenders))
+next:
+	for _, app := range m.appenders {
+		f, ok := app.(Flusher)
+		if !ok {
+			continue
+		}
+		if reflect.TypeOf(f).Comparable() {
+			for _, ff := range flushed {
+				if ff == f {
+					continue next
+				}
+			}
+			flushed = append(flushed, f)
+		}
+		f.Flush()
+	}
+}
+
 var (
 	log = &logger{
 		name: "",
+		tree: new(sync.Mutex),
 		meta: unsafe.Pointer(&meta{
 			level:     DEBUG,
 			calldepth: 1,
+			callerlvl: TRACE,
 			appenders: make(map[Level]Appender),
-			formats:   make(map[Level]string),
+			formats:   make(map[Level]*layout),
 		}),
 	}
-	pool = cache.BufCache{
-		New:  func() []byte { return make([]byte, 256) },
-		Size: 256,
-	}
+	// hostname is cached at init, since it is rarely changed.
+	hostname = "unknown"
 )
 
 func init() {
+	if h, err := os.Hostname(); err == nil {
+		hostname = h
+	}
 	log.SetLevel(DEBUG)
 	log.SetFormat("%F %T [%l] %m")
 	log.SetAppender(NewConsoleAppender())
@@ -136,20 +552,53 @@ func init() {
 
 func (l *logger) New(name string) Logger {
 	l.l.Lock()
+	for _, child := range l.children {
+		if child.name == name {
+			l.l.Unlock()
+			return child
+		}
+	}
 	m := (*meta)(atomic.LoadPointer(&l.meta)).clone()
 	m.detach = 0
 	m.calldepth = 0
 	child := &logger{
 		name: name,
 		meta: unsafe.Pointer(m),
+		tree: l.tree,
 	}
 	l.children = append(l.children, child)
 	l.l.Unlock()
 	return child
 }
 
+func (l *logger) Clone() Logger {
+	m := (*meta)(atomic.LoadPointer(&l.meta)).fork()
+	m.detach = 0
+	m.calldepth = 0
+	return &logger{
+		name: l.name,
+		meta: unsafe.Pointer(m),
+		tree: new(sync.Mutex),
+	}
+}
+
+func (l *logger) WithFields(fields ...Field) Logger {
+	return &entry{logger: l, fields: fields}
+}
+
+func (l *logger) WithError(err error) Logger {
+	if err == nil {
+		return l
+	}
+	return l.WithFields(errorFields(err)...)
+}
+
+func (l *logger) AtSite(site *CallSite) Logger {
+	return &entry{logger: l, site: site}
+}
+
 func (l *logger) Level() Level {
-	return (*meta)(atomic.LoadPointer(&l.meta)).level
+	return (*meta)(atomic.LoadPointer(&l.meta)).threshold()
 }
 
 func (l *logger) SetCallDepth(d int) {
@@ -164,267 +613,649 @@ func (l *logger) IsDebugEnabled() bool {
 	return l.Level() >= DEBUG
 }
 
-func (l *logger) setLevelInternal(detach bool, level Level) {
+func (l *logger) WouldLog(level Level) bool {
+	m := (*meta)(atomic.LoadPointer(&l.meta))
+	if !level.Enabled(m.threshold()) || m.appenders[level] == nil {
+		return false
+	}
+	limit := m.limits[level]
+	return limit == nil || limit.Available() > 0 || m.floors[level].peek() || m.samples[level].peek()
+}
+
+// set applies fn to the meta of the logger and propagates it to the
+// children which have not set the attribute flag by themselves. The fn is
+// applied to the logger before its children.
+func (l *logger) set(flag uint16, fn func(m *meta)) {
+	l.tree.Lock()
+	l.setInternal(true, flag, fn)
+	l.tree.Unlock()
+}
+
+func (l *logger) setInternal(detach bool, flag uint16, fn func(m *meta)) {
 	l.l.Lock()
-	defer l.l.Unlock()
 	m := *(*meta)(atomic.LoadPointer(&l.meta))
 	if detach {
-		m.detach |= detachlvl
-	} else if m.detach&detachlvl != 0 {
+		m.detach |= flag
+	} else if m.detach&flag != 0 {
+		l.l.Unlock()
 		return
 	}
-	m.level = level
+	fn(&m)
 	atomic.StorePointer(&l.meta, unsafe.Pointer(&m))
-	for _, child := range l.children {
-		child.setLevelInternal(false, level)
+	// the children are only appended, so the slice is a snapshot of them,
+	// the children appended later clone the meta stored above.
+	children := l.children
+	l.l.Unlock()
+	for _, child := range children {
+		child.setInternal(false, flag, fn)
 	}
 }
 
 func (l *logger) SetLevel(level Level) {
-	l.setLevelInternal(true, level)
+	l.set(detachlvl, func(m *meta) { m.level, m.bound = level, nil })
 }
 
-func (l *logger) setAppenderInternal(detach bool, appender Appender, levels ...Level) {
-	l.l.Lock()
-	defer l.l.Unlock()
-	m := *(*meta)(atomic.LoadPointer(&l.meta))
-	if detach {
-		m.detach |= detachapp
-	} else if m.detach&detachapp != 0 {
-		return
+func (l *logger) BindLevel(level *int32) {
+	l.set(detachlvl, func(m *meta) { m.bound = level })
+}
+
+func (l *logger) WithLevel(level Level) (restore func()) {
+	l.tree.Lock()
+	m := (*meta)(atomic.LoadPointer(&l.meta))
+	prev, bound, inherited := m.level, m.bound, m.detach&detachlvl == 0
+	l.setInternal(true, detachlvl, func(m *meta) { m.level, m.bound = level, nil })
+	l.tree.Unlock()
+
+	return func() {
+		l.tree.Lock()
+		l.setInternal(true, detachlvl, func(m *meta) { m.level, m.bound = prev, bound })
+		if inherited {
+			l.l.Lock()
+			m := *(*meta)(atomic.LoadPointer(&l.meta))
+			m.detach &^= detachlvl
+			atomic.StorePointer(&l.meta, unsafe.Pointer(&m))
+			l.l.Unlock()
+		}
+		l.tree.Unlock()
 	}
-	m.appenders = make(map[Level]Appender, len(LevelsToString))
+}
+
+func (l *logger) SetCallerMinLevel(level Level) {
+	l.set(detachclr, func(m *meta) { m.callerlvl = level })
+}
+
+func (l *logger) SetFatalExitCode(code int) {
+	l.set(detachext, func(m *meta) { m.exitcode = &code })
+}
+
+func (l *logger) SetIndentContinuation(enable bool) {
+	l.set(detachind, func(m *meta) { m.indent = enable })
+}
+
+func (l *logger) SetFieldSeparator(sep string) {
+	l.set(detachsep, func(m *meta) { m.sep = sep })
+}
+
+func (l *logger) SetErrorChain(enable bool) {
+	l.set(detacherr, func(m *meta) { m.errchain = enable })
+}
+
+func (l *logger) SetLevelDecoration(level Level, prefix, suffix string) {
+	l.set(detachdec, func(m *meta) {
+		decos := make(map[Level]decoration, len(m.decos)+1)
+		for lvl, d := range m.decos {
+			decos[lvl] = d
+		}
+		if prefix == "" && suffix == "" {
+			delete(decos, level)
+		} else {
+			decos[level] = decoration{prefix: prefix, suffix: suffix}
+		}
+		m.decos = decos
+	})
+}
+
+func (l *logger) SetAppender(appender Appender, levels ...Level) {
+	l.SwapAppender(appender, levels...)
+}
+
+func (l *logger) SwapAppender(appender Appender, levels ...Level) map[Level]Appender {
 	if len(levels) == 0 {
-		for level := range LevelsToString {
-			m.appenders[level] = appender
+		levels = Levels()
+	}
+	var previous map[Level]Appender
+	l.set(detachapp, func(m *meta) {
+		if previous == nil { // the first call is for l itself
+			previous = make(map[Level]Appender, len(levels))
+			for _, level := range levels {
+				previous[level] = m.appenders[level]
+			}
 		}
-	} else {
-		m0 := (*meta)(atomic.LoadPointer(&l.meta))
-		for l, a := range m0.appenders {
-			m.appenders[l] = a
+		apps := make(map[Level]Appender, len(LevelsToString))
+		for level, app := range m.appenders {
+			apps[level] = app
 		}
 		for _, level := range levels {
-			m.appenders[level] = appender
+			apps[level] = appender
 		}
+		m.appenders = apps
+	})
+	return previous
+}
+
+func (l *logger) AddAppender(appender Appender, levels ...Level) {
+	if len(levels) == 0 {
+		levels = Levels()
 	}
-	atomic.StorePointer(&l.meta, unsafe.Pointer(&m))
-	for _, child := range l.children {
-		child.setAppenderInternal(false, appender, levels...)
+	l.set(detachapp, func(m *meta) {
+		apps := make(map[Level]Appender, len(LevelsToString))
+		for level, app := range m.appenders {
+			apps[level] = app
+		}
+		for _, level := range levels {
+			apps[level] = NewMultiAppender(apps[level], appender)
+		}
+		m.appenders = apps
+	})
+}
+
+func (l *logger) AddNamedAppender(name string, appender Appender, levels ...Level) {
+	if len(levels) == 0 {
+		levels = Levels()
 	}
+	l.set(detachapp, func(m *meta) {
+		apps := make(map[Level]Appender, len(LevelsToString))
+		for level, app := range m.appenders {
+			apps[level] = app
+		}
+		named := make(map[string]Appender, len(m.named)+1)
+		for n, app := range m.named {
+			named[n] = app
+		}
+		if old := named[name]; old != nil {
+			for level, app := range apps {
+				apps[level] = removeAppender(app, old)
+			}
+		}
+		for _, level := range levels {
+			apps[level] = NewMultiAppender(apps[level], appender)
+		}
+		named[name] = appender
+		m.appenders, m.named = apps, named
+	})
 }
 
-func (l *logger) SetAppender(appender Appender, levels ...Level) {
-	l.setAppenderInternal(true, appender, levels...)
+func (l *logger) RemoveAppender(name string) {
+	l.set(detachapp, func(m *meta) {
+		old := m.named[name]
+		if old == nil {
+			return
+		}
+		apps := make(map[Level]Appender, len(LevelsToString))
+		for level, app := range m.appenders {
+			apps[level] = removeAppender(app, old)
+		}
+		named := make(map[string]Appender, len(m.named))
+		for n, app := range m.named {
+			if n != name {
+				named[n] = app
+			}
+		}
+		m.appenders, m.named = apps, named
+	})
 }
 
-func (l *logger) setFormatInternal(detach bool, fmt string, levels ...Level) {
-	l.l.Lock()
-	defer l.l.Unlock()
-	m := *(*meta)(atomic.LoadPointer(&l.meta))
-	if detach {
-		m.detach |= detachfmt
-	} else if m.detach&detachfmt != 0 {
-		return
+func (l *logger) ReplaceAllAppenders(appender Appender) {
+	l.SetAppender(appender)
+}
+
+func (l *logger) SetAppenderFunc(fn func(Level) Appender) {
+	apps := make(map[Level]Appender, len(LevelsToString))
+	for _, level := range Levels() {
+		apps[level] = fn(level)
 	}
-	m.formats = make(map[Level]string, len(LevelsToString))
+	l.set(detachapp, func(m *meta) { m.appenders = apps })
+}
+
+func (l *logger) SetOutput(w io.Writer) {
+	l.SetAppender(NewWriterAppender(w))
+}
+
+func (l *logger) SetFormat(fmt string, levels ...Level) {
+	l.setLayout(compile(fmt), levels...)
+}
+
+func (l *logger) SetFormatChecked(fmt string, levels ...Level) error {
+	f, err := compileChecked(fmt)
+	if err != nil {
+		return err
+	}
+	l.setLayout(f, levels...)
+	return nil
+}
+
+func (l *logger) SetBinaryFormat(levels ...Level) {
+	l.setLayout(&layout{binary: true}, levels...)
+}
+
+func (l *logger) setLayout(f *layout, levels ...Level) {
 	if len(levels) == 0 {
-		for level := range LevelsToString {
-			m.formats[level] = fmt
-		}
-	} else {
-		m0 := (*meta)(atomic.LoadPointer(&l.meta))
-		for l, f := range m0.formats {
-			m.formats[l] = f
+		levels = Levels()
+	}
+	l.set(detachfmt, func(m *meta) {
+		formats := make(map[Level]*layout, len(LevelsToString))
+		for level, lf := range m.formats {
+			formats[level] = lf
 		}
 		for _, level := range levels {
-			m.formats[level] = fmt
+			formats[level] = f
 		}
+		m.formats = formats
+	})
+}
+
+// jitterRate returns the rate adjusted randomly within the fraction j.
+func jitterRate(rate, j float64) float64 {
+	if j <= 0 {
+		return rate
+	} else if j >= 1 {
+		j = 0.99
 	}
-	atomic.StorePointer(&l.meta, unsafe.Pointer(&m))
-	for _, child := range l.children {
-		child.setFormatInternal(false, fmt, levels...)
-	}
+	jittermu.Lock()
+	f := jitter.Float64()
+	jittermu.Unlock()
+	return rate * (1 - j + 2*j*f)
 }
 
-func (l *logger) SetFormat(fmt string, levels ...Level) {
-	l.setFormatInternal(true, fmt, levels...)
+func (l *logger) SetRatelimit(limit int64, levels ...Level) {
+	l.setRatelimit(newBucket(limit), nil, levels)
 }
 
-func (l *logger) setRatelimitInternal(detach bool, bucket *ratelimit.Bucket, levels ...Level) {
-	l.l.Lock()
-	defer l.l.Unlock()
-	m := *(*meta)(atomic.LoadPointer(&l.meta))
-	if detach {
-		m.detach |= detachlmt
-	} else if m.detach&detachlmt != 0 {
+func (l *logger) SetRatelimitSample(limit, k int64, levels ...Level) {
+	if k <= 1 {
+		// sampling 1 in 1 keeps every log, which is no rate limit
+		l.setRatelimit(nil, nil, levels)
 		return
 	}
-	m.limits = make(map[Level]*ratelimit.Bucket, len(LevelsToString))
+	l.setRatelimit(newBucket(limit), &sampler{k: uint64(k)}, levels)
+}
+
+func (l *logger) SetRatelimitFloor(n int64, levels ...Level) {
 	if len(levels) == 0 {
-		for level := range LevelsToString {
-			m.limits[level] = bucket
-		}
-	} else {
-		m0 := (*meta)(atomic.LoadPointer(&l.meta))
-		for l, b := range m0.limits {
-			m.limits[l] = b
+		levels = Levels()
+	}
+	l.set(detachlmt, func(m *meta) {
+		floors := make(map[Level]*floor, len(LevelsToString))
+		for level, f := range m.floors {
+			floors[level] = f
 		}
 		for _, level := range levels {
-			m.limits[level] = bucket
+			if n > 0 {
+				floors[level] = &floor{n: uint64(n)}
+			} else {
+				delete(floors, level)
+			}
 		}
+		m.floors = floors
+	})
+}
+
+// newBucket returns the bucket of the rate limit adjusted by RatelimitJitter,
+// or nil if limit is not positive.
+func newBucket(limit int64) *ratelimit.Bucket {
+	if limit <= 0 {
+		return nil
 	}
-	atomic.StorePointer(&l.meta, unsafe.Pointer(&m))
-	for _, child := range l.children {
-		child.setRatelimitInternal(false, bucket, levels...)
-	}
+	return ratelimit.NewBucketWithRate(jitterRate(float64(limit), RatelimitJitter), 1)
 }
 
-func (l *logger) SetRatelimit(limit int64, levels ...Level) {
-	bucket := ratelimit.NewBucketWithRate(float64(limit), 1)
-	l.setRatelimitInternal(true, bucket, levels...)
+// setRatelimit set the rate limit of the log-levels, the logs over the limit
+// are sampled by s or dropped if s is nil. The nil bucket removes the rate
+// limit.
+func (l *logger) setRatelimit(bucket *ratelimit.Bucket, s *sampler, levels []Level) {
+	if len(levels) == 0 {
+		levels = Levels()
+	}
+	l.set(detachlmt, func(m *meta) {
+		limits := make(map[Level]*ratelimit.Bucket, len(LevelsToString))
+		for level, b := range m.limits {
+			limits[level] = b
+		}
+		samples := make(map[Level]*sampler, len(LevelsToString))
+		for level, ss := range m.samples {
+			samples[level] = ss
+		}
+		for _, level := range levels {
+			if bucket != nil {
+				limits[level] = bucket
+			} else {
+				delete(limits, level)
+			}
+			if s != nil {
+				samples[level] = s
+			} else {
+				delete(samples, level)
+			}
+		}
+		m.limits, m.samples = limits, samples
+	})
 }
 
 // Cheap integer to fixed-width decimal ASCII.  Give a negative width to avoid zero-padding.
+// The negative integer is prefixed by '-' before the padding, and the width is
+// capped at 20 digits.
 func itoa(buf []byte, i int, wid int) []byte {
 	// Assemble decimal in reverse order.
-	var b [20]byte
+	var b [21]byte
 	bp := len(b) - 1
-	for i >= 10 || wid > 1 {
+	u := uint(i)
+	if i < 0 {
+		u = uint(-i) // -math.MinInt overflows to itself, which is right as uint
+	}
+	if wid > bp {
+		wid = bp // leave the room of the sign
+	}
+	for u >= 10 || wid > 1 {
 		wid--
-		q := i / 10
-		b[bp] = byte('0' + i - q*10)
+		q := u / 10
+		b[bp] = byte('0' + u - q*10)
+		bp--
+		u = q
+	}
+	// u < 10
+	b[bp] = byte('0' + u)
+	if i < 0 {
 		bp--
-		i = q
+		b[bp] = '-'
 	}
-	// i < 10
-	b[bp] = byte('0' + i)
 	return append(buf, b[bp:]...)
 }
 
 func (l *logger) Fatal(v ...interface{}) {
-	l.dolog("", FATAL, v...)
+	l.dolog(nil, "", FATAL, v...)
+}
+
+func (l *logger) FatalWithCode(code int, v ...interface{}) {
+	l.dolog(&entry{logger: l, exitcode: &code}, "", FATAL, v...)
 }
 
 func (l *logger) Error(v ...interface{}) {
-	l.dolog("", ERROR, v...)
+	l.dolog(nil, "", ERROR, v...)
 }
 
 func (l *logger) Info(v ...interface{}) {
-	l.dolog("", INFO, v...)
+	l.dolog(nil, "", INFO, v...)
 }
 
 func (l *logger) Warn(v ...interface{}) {
-	l.dolog("", WARN, v...)
+	l.dolog(nil, "", WARN, v...)
 }
 
 func (l *logger) Debug(v ...interface{}) {
-	l.dolog("", DEBUG, v...)
+	l.dolog(nil, "", DEBUG, v...)
 }
 
 func (l *logger) Trace(v ...interface{}) {
-	l.dolog("", TRACE, v...)
+	l.dolog(nil, "", TRACE, v...)
 }
 
 func (l *logger) Fatalf(fmt string, v ...interface{}) {
-	l.dolog(fmt, FATAL, v...)
+	l.dolog(nil, fmt, FATAL, v...)
 }
 
 func (l *logger) Errorf(fmt string, v ...interface{}) {
-	l.dolog(fmt, ERROR, v...)
+	l.dolog(nil, fmt, ERROR, v...)
 }
 
 func (l *logger) Infof(fmt string, v ...interface{}) {
-	l.dolog(fmt, INFO, v...)
+	l.dolog(nil, fmt, INFO, v...)
 }
 
 func (l *logger) Warnf(fmt string, v ...interface{}) {
-	l.dolog(fmt, WARN, v...)
+	l.dolog(nil, fmt, WARN, v...)
 }
 
 func (l *logger) Debugf(fmt string, v ...interface{}) {
-	l.dolog(fmt, DEBUG, v...)
+	l.dolog(nil, fmt, DEBUG, v...)
 }
 
 func (l *logger) Tracef(fmt string, v ...interface{}) {
-	l.dolog(fmt, TRACE, v...)
+	l.dolog(nil, fmt, TRACE, v...)
+}
+
+func (l *logger) FatalKV(msg string, kv ...Field) {
+	l.dolog(&entry{logger: l, fields: kv}, "", FATAL, msg)
+}
+
+func (l *logger) ErrorKV(msg string, kv ...Field) {
+	l.dolog(&entry{logger: l, fields: kv}, "", ERROR, msg)
+}
+
+func (l *logger) InfoKV(msg string, kv ...Field) {
+	l.dolog(&entry{logger: l, fields: kv}, "", INFO, msg)
+}
+
+func (l *logger) WarnKV(msg string, kv ...Field) {
+	l.dolog(&entry{logger: l, fields: kv}, "", WARN, msg)
+}
+
+func (l *logger) DebugKV(msg string, kv ...Field) {
+	l.dolog(&entry{logger: l, fields: kv}, "", DEBUG, msg)
+}
+
+func (l *logger) TraceKV(msg string, kv ...Field) {
+	l.dolog(&entry{logger: l, fields: kv}, "", TRACE, msg)
+}
+
+func (l *logger) FatalAt(t time.Time, v ...interface{}) {
+	l.dolog(&entry{logger: l, at: t}, "", FATAL, v...)
+}
+
+func (l *logger) ErrorAt(t time.Time, v ...interface{}) {
+	l.dolog(&entry{logger: l, at: t}, "", ERROR, v...)
+}
+
+func (l *logger) InfoAt(t time.Time, v ...interface{}) {
+	l.dolog(&entry{logger: l, at: t}, "", INFO, v...)
 }
 
-func (l *logger) dolog(f string, level Level, v ...interface{}) {
+func (l *logger) WarnAt(t time.Time, v ...interface{}) {
+	l.dolog(&entry{logger: l, at: t}, "", WARN, v...)
+}
+
+func (l *logger) DebugAt(t time.Time, v ...interface{}) {
+	l.dolog(&entry{logger: l, at: t}, "", DEBUG, v...)
+}
+
+func (l *logger) TraceAt(t time.Time, v ...interface{}) {
+	l.dolog(&entry{logger: l, at: t}, "", TRACE, v...)
+}
+
+func (l *logger) dolog(e *entry, f string, level Level, v ...interface{}) {
 	m := (*meta)(atomic.LoadPointer(&l.meta))
-	if level > m.level {
+	if !level.Enabled(m.threshold()) {
 		return
 	}
 
 	app := m.appenders[level]
 	if app == nil {
+		if atomic.LoadUint32(&strictAppender) != 0 && atomic.CompareAndSwapUint32(&l.warned, 0, 1) {
+			fmt.Fprintf(stderr, "log: logger %q has no appender for %s, the logs are dropped\n",
+				l.name, LevelsToString[level])
+		}
 		return
 	}
 
-	if limit := m.limits[level]; limit != nil && limit.TakeAvailable(1) == 0 {
-		return
+	var (
+		sampled bool
+		dropped uint64
+	)
+	if limit := m.limits[level]; limit != nil && (e == nil || !e.unlimited) && limit.TakeAvailable(1) == 0 &&
+		!m.floors[level].take() {
+		if sampled, dropped = m.samples[level].take(); !sampled {
+			atomic.AddUint64(&l.drops[level&7], 1)
+			if atomic.LoadUint32(&expvarEnabled) != 0 {
+				atomic.AddUint64(&levelvars[level&7].dropped, 1)
+			}
+			return
+		}
 	}
 
 	var (
-		ok     bool
-		line   int
-		caller string
-		b      = pool.Get()[:0]
-		format = m.formats[level]
+		fields []Field
+		site   *CallSite
+		depth  = m.calldepth
+		b      = getbuf()
 		tm     = time.Now()
-		n      = len(format)
+		wall   = tm // passed to the Appender, see InfoAt
 	)
 
-	for i := 0; i < n; i++ {
-		lasti := i
-		for i < n && format[i] != '%' {
-			i++
+	if e != nil {
+		fields, site, depth = e.fields, e.site, depth+e.skip
+		if !e.at.IsZero() {
+			tm = e.at
+		}
+	}
+	if sampled {
+		// mark the structured logs, the fields of the entry are not modified
+		fields = append(fields[:len(fields):len(fields)],
+			Field{Key: "sampled", Value: true}, Field{Key: "dropped", Value: dropped})
+	}
+
+	format := m.formats[level]
+	if format != nil && format.binary {
+		b = appendBinary(b, m, level, tm, fields, f, v)
+	} else {
+		b = l.render(b, m, format, f, level, tm, e, v)
+		if ll := len(b); ll == 0 || b[ll-1] != '\n' {
+			b = append(b, '\n')
+		}
+	}
+
+	if atomic.LoadUint32(&expvarEnabled) != 0 {
+		atomic.AddUint64(&levelvars[level&7].emitted, 1)
+		atomic.AddUint64(&levelvars[level&7].bytes, uint64(len(b)))
+	}
+
+	if rapp, ok := app.(RecordAppender); ok {
+		if s := loadStatics(); len(s.fields) != 0 {
+			fields = s.with(fields)
+		}
+		n := len(b)
+		b = appendMessage(b, m, f, v)
+		r := &Record{
+			Level:   level,
+			Time:    tm,
+			Message: b[n:],
+			Fields:  fields,
+			Data:    b[:n],
 		}
-		if i > lasti {
-			b = append(b, format[lasti:i]...)
+		if level.Enabled(m.callerlvl) && needsCaller(rapp) {
+			r.PC, r.Caller, r.Line = site.callerPC(depth + 2)
 		}
-		if i >= n { // done processing format string
-			break
+		rapp.OutputRecord(r)
+	} else {
+		app.Output(level, wall, b)
+	}
+	putbuf(b)
+
+	if level == FATAL && ExitOnFatal {
+		m.flush()
+		code := FatalExitCode
+		if e != nil && e.exitcode != nil {
+			code = *e.exitcode
+		} else if m.exitcode != nil {
+			code = *m.exitcode
 		}
+		exit(code)
+	}
+}
+
+// render appends the log formatted by the layout to b.
+func (l *logger) render(b []byte, m *meta, format *layout, f string, level Level, tm time.Time, e *entry, v []interface{}) []byte {
+	var (
+		line   int
+		seq    uint64
+		caller string
+		fields []Field
+		site   *CallSite
+		depth  = m.calldepth
+	)
+
+	if format == nil {
+		return b
+	}
 
-		i++ // skip '%'
+	if e != nil {
+		fields = e.fields
+		if format.caller {
+			site, depth = e.site, depth+e.skip
+		}
+	}
 
-		switch format[i] {
+	for _, vb := range format.verbs {
+		switch vb.op {
+		case 0:
+			b = append(b, vb.lit...)
 		case 'm':
-			if f != "" {
-				fmt.Fprintf((*bufw)(noescape(unsafe.Pointer(&b))), f, v...)
-			} else {
-				fmt.Fprint((*bufw)(noescape(unsafe.Pointer(&b))), v...)
+			deco, decorated := m.decos[level]
+			if decorated {
+				b = append(b, deco.prefix...)
+			}
+			n := len(b)
+			b = appendMessage(b, m, f, v)
+			b = appendFields(b, fields, m.errchain)
+			if m.indent {
+				width := utf8.RuneCount(b[bytes.LastIndexByte(b[:n], '\n')+1 : n])
+				b = indentContinuation(b, n, width)
+			}
+			if decorated {
+				b = append(b, deco.suffix...)
 			}
 		case 'l':
-			b = append(b, LevelsToString[level]...)
+			b = append(b, levelName(level)...)
 		case 'C':
+			if !level.Enabled(m.callerlvl) {
+				b = append(b, '-')
+				break
+			}
 			if caller == "" {
-				_, caller, line, ok = runtime.Caller(m.calldepth + 2)
-				if !ok {
-					caller = "???"
-				}
+				caller, line = site.caller(depth + 3)
 			}
-			b = append(b, caller...)
+			b = append(b, trimSourceRoot(caller)...)
 		case 'c':
+			if !level.Enabled(m.callerlvl) {
+				b = append(b, '-')
+				break
+			}
 			if caller == "" {
-				_, caller, line, ok = runtime.Caller(m.calldepth + 2)
-				if !ok {
-					caller = "???"
-				}
+				caller, line = site.caller(depth + 3)
 			}
 			b = append(b, filepath.Base(caller)...)
 		case 'L':
+			if !level.Enabled(m.callerlvl) {
+				b = append(b, '-')
+				break
+			}
 			if caller == "" {
-				_, caller, line, ok = runtime.Caller(m.calldepth + 2)
-				if !ok {
-					caller = "???"
-				}
+				caller, line = site.caller(depth + 3)
 			}
 			b = itoa(b, line, -1)
-		case '%':
-			b = append(b, '%')
-		case 'n':
-			b = append(b, '\n')
+		case 'i':
+			if seq == 0 {
+				seq = atomic.AddUint64(&l.seq, 1)
+			}
+			b = strconv.AppendUint(b, seq, 10)
+		case 'h':
+			b = append(b, hostname...)
+		case 'S':
+			b = append(b, loadStatics().text...)
+		case 'e':
+			if prev := atomic.SwapInt64(&l.last, tm.UnixNano()); prev == 0 {
+				b = append(b, '-')
+			} else {
+				b = append(b, time.Duration(tm.UnixNano()-prev).String()...)
+			}
 		case 'F':
 			b = tm.AppendFormat(b, "2006-01-02")
 		case 'D':
@@ -444,19 +1275,171 @@ func (l *logger) dolog(f string, level Level, v ...interface{}) {
 		}
 	}
 
-	if ll := len(b); ll == 0 || b[ll-1] != '\n' {
-		b = append(b, '\n')
+	return b
+}
+
+// indentContinuation indents the lines following the first line of b[n:]
+// with width spaces, the trailing newline does not start a line.
+func indentContinuation(b []byte, n, width int) []byte {
+	if width == 0 || len(b)-n < 2 {
+		return b
+	}
+	k := bytes.Count(b[n:len(b)-1], []byte{'\n'})
+	if k == 0 {
+		return b
 	}
+	last := len(b) - 1
+	for i := 0; i < k*width; i++ {
+		b = append(b, ' ')
+	}
+	// move the bytes backward and fill the indents from the tail.
+	j := len(b)
+	for i := last; j > i+1; i-- {
+		if b[i] == '\n' && i != last {
+			j -= width
+			for s := j; s < j+width; s++ {
+				b[s] = ' '
+			}
+		}
+		j--
+		b[j] = b[i]
+	}
+	return b
+}
+
+// appendMessage appends the log message formatted with `fmt.Sprintf` or
+// `fmt.Sprint` to b, the arguments are joined by the separator instead if
+// it is set, and the errors are rendered with their chains if enabled.
+func appendMessage(b []byte, m *meta, f string, v []interface{}) []byte {
+	sep := m.sep
+	if sep == "" && f == "" && atomic.LoadUint32(&printSpacing) != 0 {
+		sep = " "
+	}
+	if f != "" {
+		if m.errchain && hasError(v) {
+			v = chainErrors(v)
+		}
+		fmt.Fprintf((*bufw)(noescape(unsafe.Pointer(&b))), f, v...)
+	} else if sep != "" || (m.errchain && hasError(v)) {
+		for i := range v {
+			if i != 0 && sep != "" {
+				b = append(b, sep...)
+			} else if i != 0 && !isString(v[i-1]) && !isString(v[i]) {
+				b = append(b, ' ') // like fmt.Sprint
+			}
+			if err, ok := v[i].(error); ok && m.errchain {
+				b = appendErrorChain(b, err)
+			} else {
+				fmt.Fprint((*bufw)(noescape(unsafe.Pointer(&b))), v[i:i+1]...)
+			}
+		}
+	} else if bb, ok := appendPrint(b, v); ok {
+		b = bb
+	} else {
+		fmt.Fprint((*bufw)(noescape(unsafe.Pointer(&b))), v...)
+	}
+	return b
+}
 
-	app.Output(level, tm, b)
-	pool.Put(b)
+// appendPrint appends the arguments formatted like `fmt.Sprint` to b without
+// the overhead of fmt if they are all the strings, bools or integers, e.g.
+// Info("done", n). It returns false with b untouched for the other types.
+func appendPrint(b []byte, v []interface{}) ([]byte, bool) {
+	n := len(b)
+	for i, a := range v {
+		if i != 0 {
+			_, s0 := v[i-1].(string)
+			if _, s1 := a.(string); !s0 && !s1 {
+				b = append(b, ' ')
+			}
+		}
+		switch a := a.(type) {
+		case string:
+			b = append(b, a...)
+		case bool:
+			b = strconv.AppendBool(b, a)
+		case int:
+			b = strconv.AppendInt(b, int64(a), 10)
+		case int32:
+			b = strconv.AppendInt(b, int64(a), 10)
+		case int64:
+			b = strconv.AppendInt(b, a, 10)
+		case uint:
+			b = strconv.AppendUint(b, uint64(a), 10)
+		case uint32:
+			b = strconv.AppendUint(b, uint64(a), 10)
+		case uint64:
+			b = strconv.AppendUint(b, a, 10)
+		default:
+			return b[:n], false
+		}
+	}
+	return b, true
+}
 
-	if level == FATAL && ExitOnFatal {
-		if flusher, ok := app.(Flusher); ok {
-			flusher.Flush()
+func hasError(v []interface{}) bool {
+	for _, a := range v {
+		if _, ok := a.(error); ok {
+			return true
 		}
-		os.Exit(-1)
 	}
+	return false
+}
+
+// chainErrors returns a copy of v whose errors are replaced by chainError,
+// except for the errors formatting themselves.
+func chainErrors(v []interface{}) []interface{} {
+	args := make([]interface{}, len(v))
+	for i, a := range v {
+		if err, ok := a.(error); ok {
+			if _, ok := a.(fmt.Formatter); !ok {
+				a = chainError{err}
+			}
+		}
+		args[i] = a
+	}
+	return args
+}
+
+// chainError is an error whose message includes its chain, see
+// appendErrorChain.
+type chainError struct {
+	error
+}
+
+func (e chainError) Error() string {
+	return string(appendErrorChain(nil, e.error))
+}
+
+func (e chainError) Unwrap() error {
+	return e.error
+}
+
+func isString(a interface{}) bool {
+	return a != nil && reflect.TypeOf(a).Kind() == reflect.String
+}
+
+// appendErrorChain appends the message of err followed by the messages of
+// its wrapped errors which are not included yet.
+func appendErrorChain(b []byte, err error) []byte {
+	n := len(b)
+	b = append(b, errorString(err)...)
+	for err = unwrap(err); err != nil; err = unwrap(err) {
+		if msg := errorString(err); !strings.Contains(b2s(b[n:]), msg) {
+			b = append(b, ": "...)
+			b = append(b, msg...)
+		}
+	}
+	return b
+}
+
+// unwrap returns the wrapped error like errors.Unwrap, the nil pointer is
+// not unwrapped, see errorString.
+func unwrap(err error) error {
+	if nilPointer(err) {
+		return nil
+	}
+	return errors.Unwrap(err)
 }
 
 type bufw []byte
@@ -2,14 +2,56 @@ package log
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// newTestLogger returns a new root logger of the name for the test, which is
+// configured like the default global logger. Unlike New, it is not shared
+// with the other tests or the previous runs of the test, see -count.
+func newTestLogger(name string) Logger {
+	lg := &logger{
+		name: name,
+		meta: unsafe.Pointer(&meta{
+			callerlvl: TRACE,
+			appenders: make(map[Level]Appender),
+			formats:   make(map[Level]*layout),
+		}),
+		tree: new(sync.Mutex),
+	}
+	lg.SetLevel(DEBUG)
+	lg.SetFormat("%F %T [%l] %m")
+	lg.SetAppender(NewConsoleAppender())
+	return lg
+}
+
+// setGlobal configures global logger with the level DEBUG, the format and
+// the appender for the test, and restores the default after the test, so
+// that the test does not depend on the global logger left by the others.
+func setGlobal(t testing.TB, format string, app Appender) {
+	SetLevel(DEBUG)
+	SetFormat(format)
+	SetAppender(app)
+	t.Cleanup(func() {
+		SetLevel(DEBUG)
+		SetFormat("%F %T [%l] %m")
+		SetAppender(NewConsoleAppender())
+	})
+}
+
 type dap struct {
 	l Level
 	d string
@@ -159,7 +201,7 @@ func (a *la) check(t *testing.T, level Level, vv int) {
 
 func TestLoggerSetLevel(t *testing.T) {
 	a := &la{m: make(map[Level]int, len(StringToLevels))}
-	for l := range LevelsToString {
+	for _, l := range Levels() {
 		a.m[l] = 0
 	}
 	tt := []struct {
@@ -265,6 +307,139 @@ func TestSetRatelimit(t *testing.T) {
 	assert.True(a1.m[ERROR]+a1.m[FATAL] < 110, "%d - %d", a1.m[ERROR], a1.m[FATAL])
 }
 
+func TestSetRatelimitZero(t *testing.T) {
+	var (
+		a      = &la{m: make(map[Level]int)}
+		assert = assert.New(t)
+		lg     = newTestLogger("ratelimit-zero")
+	)
+
+	lg.SetAppender(a)
+	lg.SetRatelimit(1, INFO)
+	for i := 0; i < 3; i++ {
+		lg.Info("info")
+	}
+	assert.Equal(1, a.m[INFO])
+	lg.SetRatelimit(0, INFO)
+	for i := 0; i < 3; i++ {
+		lg.Info("info")
+	}
+	assert.Equal(4, a.m[INFO])
+}
+
+func TestSetRatelimitSample(t *testing.T) {
+	var (
+		a      = &la{m: make(map[Level]int)}
+		assert = assert.New(t)
+		lg     = newTestLogger("sample").(*logger)
+	)
+
+	lg.SetLevel(TRACE)
+	lg.SetAppender(a)
+	lg.SetRatelimitSample(1, 5, INFO)
+	for i := 0; i < 51; i++ {
+		lg.Info("info message")
+		lg.Warn("warn message")
+	}
+	// the first one takes the burst, then 1 in 5 of the other 50
+	assert.Equal(11, a.m[INFO])
+	assert.Equal(51, a.m[WARN])
+	assert.EqualValues(40, atomic.LoadUint64(&lg.drops[INFO]))
+
+	lg.SetRatelimit(1, INFO)
+	for i := 0; i < 10; i++ {
+		lg.Info("info message")
+	}
+	// the new bucket takes the burst, the others are dropped
+	assert.Equal(12, a.m[INFO])
+
+	// sampling 1 in 1 or less keeps every log
+	for _, k := range []int64{1, 0, -1} {
+		lg.SetRatelimit(1, INFO)
+		lg.SetRatelimitSample(1, k, INFO)
+		before := a.m[INFO]
+		for i := 0; i < 10; i++ {
+			lg.Info("info message")
+		}
+		assert.Equal(before+10, a.m[INFO], "k=%d", k)
+	}
+}
+
+func TestSetRatelimitFloor(t *testing.T) {
+	var (
+		a      = &la{m: make(map[Level]int)}
+		assert = assert.New(t)
+		lg     = newTestLogger("floor").(*logger)
+	)
+
+	lg.SetLevel(TRACE)
+	lg.SetAppender(a)
+	lg.SetRatelimit(1)
+	lg.SetRatelimitFloor(5, INFO)
+	for i := 0; i < 50; i++ {
+		lg.Debug("debug message")
+		lg.Info("info message")
+	}
+	// the debug takes the shared burst, the floor lets the info through
+	// even when over the limit, the window may cross a second
+	assert.Equal(1, a.m[DEBUG])
+	assert.True(a.m[INFO] >= 5 && a.m[INFO] <= 10, "info %d", a.m[INFO])
+
+	lg.SetRatelimitFloor(0, INFO)
+	before := a.m[INFO]
+	for i := 0; i < 10; i++ {
+		lg.Info("info message")
+	}
+	assert.True(a.m[INFO]-before <= 1)
+
+	// WouldLog agrees with the floor and the sampling over the limit
+	lg = newTestLogger("floor-wouldlog").(*logger)
+	lg.SetLevel(TRACE)
+	lg.SetAppender(a)
+	lg.SetRatelimit(1)
+	lg.SetRatelimitFloor(2, INFO)
+	lg.SetRatelimitSample(1, 3, WARN)
+	lg.Debug("debug message")
+	assert.False(lg.WouldLog(DEBUG))
+	sec := time.Now().Unix()
+	assert.True(lg.WouldLog(INFO))
+	lg.Info("info message")
+	lg.Info("info message")
+	if time.Now().Unix() == sec {
+		assert.False(lg.WouldLog(INFO), "the floor is used up")
+	}
+	lg.Warn("warn message") // takes the burst of its own bucket
+	assert.False(lg.WouldLog(WARN))
+	lg.Warn("warn message")
+	lg.Warn("warn message")
+	assert.True(lg.WouldLog(WARN), "the next one is sampled")
+	before = a.m[WARN]
+	lg.Warn("warn message")
+	assert.Equal(before+1, a.m[WARN])
+}
+
+func TestItoa(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range []struct {
+		i, wid int
+		want   string
+	}{
+		{0, -1, "0"},
+		{0, 2, "00"},
+		{7, 2, "07"},
+		{123, 2, "123"},
+		{-1, -1, "-1"},
+		{-7, 2, "-07"},
+		{-123, 2, "-123"},
+		{math.MaxInt64, -1, "9223372036854775807"},
+		{math.MinInt64, -1, "-9223372036854775808"},
+		{5, 30, "00000000000000000005"},
+		{-5, 30, "-00000000000000000005"},
+	} {
+		assert.Equal(c.want, string(itoa([]byte("x"), c.i, c.wid))[1:], "%d %d", c.i, c.wid)
+	}
+}
+
 type null struct{}
 
 func (n *null) Output(level Level, t time.Time, data []byte) {
@@ -281,6 +456,52 @@ func BenchmarkLogger(b *testing.B) {
 	})
 }
 
+func TestAppendPrint(t *testing.T) {
+	assert := assert.New(t)
+	type named string
+	for _, v := range [][]interface{}{
+		{"a"},
+		{"a", "b"},
+		{1, 2, "a", 3, true, "b", "c"},
+		{int32(-1), int64(2), uint(3), uint32(4), uint64(5), false},
+		{"a", 1.5, 2},
+		{named("a"), 1, nil, errors.New("e")},
+	} {
+		b := appendMessage([]byte("x"), &meta{}, "", v)
+		assert.Equal("x"+fmt.Sprint(v...), string(b), "%#v", v)
+	}
+
+	// the constants are not boxed, and the arguments do not escape if
+	// the logger is not called through the interface
+	lg := newTestLogger("appendprint").(*logger)
+	lg.SetAppender(&null{})
+	assert.Equal(0.0, testing.AllocsPerRun(100, func() { lg.Info("done", 12345678) }))
+}
+
+var benchmsg = "BenchmarkLoggerPrint running"
+
+func BenchmarkLoggerPrint(b *testing.B) {
+	SetAppender(&null{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			Info(benchmsg)
+		}
+	})
+}
+
+func BenchmarkLoggerPrintArgs(b *testing.B) {
+	SetAppender(&null{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			Info(benchmsg, 12345678)
+		}
+	})
+}
+
 var (
 	bench0, bench1, bench2, bench3, bench4 Logger
 )
@@ -320,3 +541,894 @@ func BenchmarkLoggerWithMultiInherit10(b *testing.B) {
 func BenchmarkLoggerWithMultiInherit20(b *testing.B) {
 	benmarkLoggerWithMultiInherit(b, 20)
 }
+
+func TestSetOutput(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		buf    = bytes.NewBuffer(nil)
+	)
+
+	defer SetAppender(NewConsoleAppender())
+	SetLevel(DEBUG)
+	SetFormat("[%l] %m")
+	SetOutput(buf)
+
+	Info("hello ", "world")
+	Debugf("%d-%d", 1, 2)
+	assert.Equal("[INFO] hello world\n[DEBUG] 1-2\n", buf.String())
+}
+
+func TestSetCallerMinLevel(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("caller")
+	)
+
+	lg.SetLevel(TRACE)
+	lg.SetAppender(d)
+	lg.SetFormat("%c:%L %m")
+	lg.SetCallerMinLevel(WARN)
+
+	lg.Info("info")
+	assert.Equal("-:- info\n", d.d)
+	lg.Warn("warn")
+	assert.True(strings.HasPrefix(d.d, "logger_test.go:"), d.d)
+	lg.Error("error")
+	assert.True(strings.HasPrefix(d.d, "logger_test.go:"), d.d)
+
+	child := lg.New("child")
+	child.Debug("debug")
+	assert.Equal("-:- debug\n", d.d)
+	child.SetCallerMinLevel(TRACE)
+	child.Debug("debug")
+	assert.True(strings.HasPrefix(d.d, "logger_test.go:"), d.d)
+}
+
+func TestSetIndentContinuation(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("indent")
+	)
+
+	lg.SetFormat("[%l] %m")
+	lg.SetAppender(d)
+	lg.Error("panic: boom\ngoroutine 1")
+	assert.Equal("[ERROR] panic: boom\ngoroutine 1\n", d.d)
+
+	lg.SetIndentContinuation(true)
+	lg.Error("panic: boom\ngoroutine 1")
+	assert.Equal("[ERROR] panic: boom\n        goroutine 1\n", d.d)
+	lg.Errorf("a\nb\n")
+	assert.Equal("[ERROR] a\n        b\n", d.d)
+	lg.Info("one line")
+	assert.Equal("[INFO] one line\n", d.d)
+
+	child := lg.New("child")
+	child.SetFormat("%l: %m")
+	child.Warn("x\ny\nz")
+	assert.Equal("WARN: x\n      y\n      z\n", d.d)
+}
+
+func TestSetFieldSeparator(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("separator")
+	)
+
+	lg.SetFormat("%l\t%m")
+	lg.SetAppender(d)
+	lg.Info("a", "b", 1, 2)
+	assert.Equal("INFO\tab1 2\n", d.d)
+
+	lg.SetFieldSeparator("\t")
+	lg.Info("a", "b", 1, 2)
+	assert.Equal("INFO\ta\tb\t1\t2\n", d.d)
+	lg.Infof("%s,%s", "a", "b")
+	assert.Equal("INFO\ta,b\n", d.d)
+
+	child := lg.New("child")
+	child.Warn("x", errors.New("y"))
+	assert.Equal("WARN\tx\ty\n", d.d)
+
+	lg.SetFieldSeparator("")
+	child.Warn("x", "y")
+	assert.Equal("WARN\txy\n", d.d)
+}
+
+func TestSetPrintSpacing(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("spacing")
+	)
+
+	lg.SetFormat("%m")
+	lg.SetAppender(d)
+	lg.Info("a", 1, "b", 2, 3, errors.New("c"))
+	assert.Equal("a1b2 3 c\n", d.d)
+
+	SetPrintSpacing(true)
+	defer SetPrintSpacing(false)
+	lg.Info("a", 1, "b", 2, 3, errors.New("c"))
+	assert.Equal("a 1 b 2 3 c\n", d.d)
+	lg.Info("a")
+	assert.Equal("a\n", d.d)
+	lg.Infof("%s%d", "a", 1)
+	assert.Equal("a1\n", d.d)
+
+	lg.SetFieldSeparator(",")
+	lg.Info("a", 1, "b")
+	assert.Equal("a,1,b\n", d.d)
+}
+
+func TestSetLevelDecoration(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("decoration")
+	)
+
+	lg.SetFormat("[%l] %m")
+	lg.SetAppender(d)
+	lg.SetLevelDecoration(ERROR, ">>> ", " <<<")
+	lg.Error("failed")
+	assert.Equal("[ERROR] >>> failed <<<\n", d.d)
+	lg.Info("ok")
+	assert.Equal("[INFO] ok\n", d.d)
+
+	child := lg.New("child")
+	child.Error("failed")
+	assert.Equal("[ERROR] >>> failed <<<\n", d.d)
+	child.SetLevelDecoration(WARN, "! ", "")
+	child.Warn("careful")
+	assert.Equal("[WARN] ! careful\n", d.d)
+	lg.Warn("careful")
+	assert.Equal("[WARN] careful\n", d.d)
+
+	lg.SetLevelDecoration(ERROR, "", "")
+	lg.Error("failed")
+	assert.Equal("[ERROR] failed\n", d.d)
+}
+
+func TestDefault(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = Default()
+	)
+
+	setGlobal(t, "%c [%l] %m", d)
+
+	lg.SetLevel(WARN)
+	assert.Equal(WARN, log.Level())
+	d.d = ""
+	Info("dropped")
+	assert.Equal("", d.d)
+	Warn("global")
+	assert.Equal("logger_test.go [WARN] global\n", d.d)
+
+	lg.SetLevel(INFO)
+	Info("global")
+	assert.Equal("logger_test.go [INFO] global\n", d.d)
+	lg.Info("default")
+	assert.Equal("logger_test.go [INFO] default\n", d.d)
+	lg.WithFields(Field{"k", "v"}).Info("default")
+	assert.Equal("logger_test.go [INFO] default k=v\n", d.d)
+}
+
+func TestSwapAppender(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d0     = &dap{}
+		d1     = &dap{}
+		d2     = &dap{}
+		lg     = newTestLogger("swap")
+	)
+
+	lg.SetFormat("%m")
+	lg.SetAppender(d0)
+	lg.SetAppender(d1, ERROR)
+	origin := (*meta)(atomic.LoadPointer(&lg.(*logger).meta)).appenders
+
+	previous := lg.SwapAppender(d2, ERROR, WARN)
+	assert.Equal(map[Level]Appender{ERROR: d1, WARN: d0}, previous)
+	for level, app := range previous {
+		lg.SetAppender(app, level)
+	}
+	assert.Equal(origin, (*meta)(atomic.LoadPointer(&lg.(*logger).meta)).appenders)
+
+	previous = lg.SwapAppender(d2)
+	assert.Equal(origin, previous)
+	lg.Error("swapped")
+	assert.Equal("swapped\n", d2.d)
+	for level, app := range previous {
+		lg.SetAppender(app, level)
+	}
+	lg.Error("restored")
+	assert.Equal("restored\n", d1.d)
+	lg.Info("restored")
+	assert.Equal("restored\n", d0.d)
+}
+
+func TestNewDeduplicate(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		parent = New("dedup")
+	)
+
+	x := parent.New("x")
+	assert.True(x == parent.New("x"))
+	assert.True(x != parent.New("y"))
+	assert.True(x != x.New("x"))
+	assert.Equal(2, len(parent.(*logger).children))
+	assert.True(parent == New("dedup"))
+
+	x.SetLevel(ERROR)
+	assert.Equal(ERROR, parent.New("x").Level())
+}
+
+func TestClone(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		a      = &la{m: make(map[Level]int)}
+		b      = &la{m: make(map[Level]int)}
+		parent = newTestLogger("clone")
+	)
+
+	parent.SetLevel(INFO)
+	parent.SetAppender(a)
+	parent.SetRatelimit(1)
+	clone := parent.Clone()
+	assert.True(clone != parent)
+	assert.Empty(parent.(*logger).children)
+	assert.Equal(INFO, clone.Level())
+
+	parent.SetLevel(ERROR)
+	parent.SetAppender(b)
+	assert.Equal(INFO, clone.Level())
+	clone.Info("info message")
+	clone.Info("info message")
+	assert.Equal(1, a.m[INFO])
+	assert.Zero(b.m[INFO])
+
+	// the clone does not take the rate limit of the parent
+	parent.Error("error message")
+	assert.Equal(1, b.m[ERROR])
+
+	c := clone.New("child")
+	clone.SetLevel(DEBUG)
+	assert.Equal(DEBUG, c.Level())
+	assert.Equal(ERROR, parent.Level())
+}
+
+// opaqueError wraps an error without including its message.
+type opaqueError struct {
+	msg string
+	err error
+}
+
+func (e *opaqueError) Error() string { return e.msg }
+func (e *opaqueError) Unwrap() error { return e.err }
+
+func TestSetErrorChain(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("errchain")
+		inner  = errors.New("permission denied")
+		middle = &opaqueError{"open config", inner}
+		outer  = fmt.Errorf("load: %w", middle)
+	)
+
+	lg.SetFormat("%m")
+	lg.SetAppender(d)
+	lg.Error(outer)
+	assert.Equal("load: open config\n", d.d)
+
+	lg.SetErrorChain(true)
+	lg.Error(outer)
+	assert.Equal("load: open config: permission denied\n", d.d)
+	lg.Error("failed ", middle, 1, 2)
+	assert.Equal("failed open config: permission denied 1 2\n", d.d)
+	lg.Error(inner, inner)
+	assert.Equal("permission denied permission denied\n", d.d)
+	lg.Info("no error ", 1, 2)
+	assert.Equal("no error 1 2\n", d.d)
+
+	lg.SetFieldSeparator(" | ")
+	lg.Error("failed", middle)
+	assert.Equal("failed | open config: permission denied\n", d.d)
+}
+
+// formatError formats itself.
+type formatError struct{ error }
+
+func (e formatError) Format(s fmt.State, verb rune) { fmt.Fprint(s, "formatted") }
+
+func TestErrorChainFormat(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		r      = &recordap{}
+		lg     = newTestLogger("errchain-format")
+		err    = &opaqueError{"level 1", &opaqueError{"level 2", errors.New("level 3")}}
+	)
+
+	lg.SetFormat("%m")
+	lg.SetAppender(d)
+	lg.Errorf("failed: %v", err)
+	assert.Equal("failed: level 1\n", d.d)
+
+	lg.SetErrorChain(true)
+	lg.Errorf("failed: %v", err)
+	assert.Equal("failed: level 1: level 2: level 3\n", d.d)
+	lg.Errorf("failed: %q %d", err, 1)
+	assert.Equal("failed: \"level 1: level 2: level 3\" 1\n", d.d)
+	lg.Errorf("failed: %v", formatError{err})
+	assert.Equal("failed: formatted\n", d.d)
+	lg.Error(err)
+	assert.Equal("level 1: level 2: level 3\n", d.d)
+
+	lg.SetAppender(r)
+	lg.Errorf("failed: %v", err)
+	assert.Equal("[ERROR] failed: level 1: level 2: level 3\n", r.data)
+}
+
+func benchmarkLoggerCaller(b *testing.B, level Level) {
+	lg := newTestLogger("bench-caller")
+	lg.SetAppender(&null{})
+	lg.SetFormat("%F %T %c:%L [%l] %m")
+	lg.SetCallerMinLevel(level)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			lg.Infof("BenchmarkLoggerCaller running %s %d", "go go go", 12345678)
+		}
+	})
+}
+
+func BenchmarkLoggerCaller(b *testing.B) {
+	benchmarkLoggerCaller(b, TRACE)
+}
+
+func BenchmarkLoggerCallerMinLevel(b *testing.B) {
+	benchmarkLoggerCaller(b, WARN)
+}
+
+func TestSequenceNumber(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("seq")
+	)
+
+	lg.SetLevel(TRACE)
+	lg.SetAppender(d)
+	lg.SetFormat("%i %m %i")
+
+	lg.Info("a")
+	assert.Equal("1 a 1\n", d.d)
+	lg.Debug("b")
+	assert.Equal("2 b 2\n", d.d)
+	lg.Error("c")
+	assert.Equal("3 c 3\n", d.d)
+
+	// the dropped log does not consume a sequence number
+	lg.SetRatelimit(1)
+	lg.Info("d")
+	assert.Equal("4 d 4\n", d.d)
+	lg.Info("e")
+	assert.Equal("4 d 4\n", d.d)
+	lg.SetRatelimit(1000)
+	lg.Info("f")
+	assert.Equal("5 f 5\n", d.d)
+}
+
+func TestHostname(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("hostname")
+	)
+
+	h, err := os.Hostname()
+	if err != nil {
+		h = "unknown"
+	}
+
+	lg.SetAppender(d)
+	lg.SetFormat("%h %m")
+	lg.Info("a")
+	assert.Equal(h+" a\n", d.d)
+}
+
+func TestFatalExitCode(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		code   = -1
+		lg     = newTestLogger("exit")
+	)
+
+	exit = func(c int) { code = c }
+	ExitOnFatal = true
+	defer func() {
+		exit = os.Exit
+		ExitOnFatal = false
+		FatalExitCode = 1
+	}()
+
+	lg.SetAppender(&null{})
+	lg.Fatal("fatal")
+	assert.Equal(1, code)
+	FatalExitCode = 3
+	lg.Fatal("fatal")
+	assert.Equal(3, code)
+
+	lg.SetFatalExitCode(7)
+	lg.Fatal("fatal")
+	assert.Equal(7, code)
+	lg.New("child").Fatal("fatal")
+	assert.Equal(7, code)
+}
+
+func TestLogAt(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		r      = &recordap{}
+		lg     = newTestLogger("logat")
+		at     = time.Date(2020, 1, 2, 3, 4, 5, 0, time.Local)
+	)
+
+	lg.SetLevel(TRACE)
+	lg.SetFormat("%F %T [%l] %m")
+	lg.SetAppender(d)
+	lg.InfoAt(at, "a")
+	assert.Equal("2020-01-02 03:04:05 [INFO] a\n", d.d)
+	lg.WithFields(F("k", 1)).TraceAt(at.Add(time.Hour), "b")
+	assert.Equal("2020-01-02 04:04:05 [TRACE] b k=1\n", d.d)
+	lg.Info("c")
+	assert.NotContains(d.d, "2020-01-02")
+
+	lg.SetAppender(r)
+	lg.ErrorAt(at, "d")
+	assert.Equal(at, r.r.Time)
+	assert.Equal("logger_test.go", filepath.Base(r.r.Caller))
+
+	// the RotateAppender writes the logs at any time to the current file
+	filename := filepath.Join(t.TempDir(), "a.log")
+	app, err := NewHourlyRotateAppender(filename)
+	if !assert.NoError(err) {
+		return
+	}
+	defer app.Close()
+	rt := app.rt
+	lg.SetFormat("%m")
+	lg.SetAppender(app)
+	lg.InfoAt(time.Now().Add(24*time.Hour), "future")
+	lg.InfoAt(at, "past")
+	assert.Equal(rt, app.rt)
+	data, err := ioutil.ReadFile(filename)
+	assert.NoError(err)
+	assert.Equal("future\npast\n", string(data))
+}
+
+func TestFatalWithCode(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		a      = &flushap{}
+		lg     = newTestLogger("exitwithcode")
+		code   = -1
+	)
+
+	exit = func(c int) {
+		assert.Equal(1, a.flushed, "flushed before exiting")
+		code = c
+	}
+	ExitOnFatal = true
+	defer func() {
+		exit = os.Exit
+		ExitOnFatal = false
+	}()
+
+	lg.SetAppender(a)
+	lg.SetFatalExitCode(7)
+	lg.FatalWithCode(5, "fatal")
+	assert.Equal(5, code)
+	a.flushed = 0
+	lg.WithFields(Field{"k", "v"}).FatalWithCode(6, "fatal")
+	assert.Equal(6, code)
+	a.flushed = 0
+	lg.Fatal("fatal")
+	assert.Equal(7, code)
+}
+
+type flushap struct {
+	buffered int
+	flushed  int
+	flushes  int
+}
+
+func (a *flushap) Output(level Level, t time.Time, data []byte) {
+	a.buffered++
+}
+
+func (a *flushap) Flush() error {
+	a.flushes++
+	a.flushed, a.buffered = a.flushed+a.buffered, 0
+	return nil
+}
+
+func TestFatalFlushAllAppenders(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		a0     = &flushap{}
+		a1     = &flushap{}
+		lg     = newTestLogger("flushall")
+		done   bool
+	)
+
+	exit = func(int) {
+		assert.Equal(2, a0.flushed)
+		assert.Equal(1, a1.flushed)
+		done = true
+	}
+	ExitOnFatal = true
+	defer func() {
+		exit = os.Exit
+		ExitOnFatal = false
+	}()
+
+	lg.SetLevel(TRACE)
+	lg.SetAppender(a0)
+	lg.SetAppender(a1, DEBUG, TRACE)
+	lg.Debug("debug")
+	lg.Info("info")
+	lg.Fatal("fatal")
+	assert.True(done)
+	assert.Equal(1, a0.flushes)
+	assert.Equal(1, a1.flushes)
+}
+
+func TestConcurrentSetAppender(t *testing.T) {
+	var (
+		wg     sync.WaitGroup
+		parent = newTestLogger("concurrent")
+		child  = parent.New("child")
+	)
+
+	for i := 0; i < 4; i++ {
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				parent.SetAppender(&null{})
+				parent.SetFormat("%m", INFO)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				child.SetAppender(&null{}, INFO)
+				child.SetLevel(TRACE)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				child.New("grandchild").Info("message")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				parent.Info("message")
+				child.Info("message")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRatelimitJitter(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(100.0, jitterRate(100, 0))
+	var lo, hi bool
+	for i := 0; i < 10000; i++ {
+		r := jitterRate(100, 0.1)
+		assert.True(r >= 90 && r <= 110, "%v", r)
+		lo = lo || r < 95
+		hi = hi || r > 105
+	}
+	assert.True(lo && hi)
+	for i := 0; i < 10000; i++ {
+		r := jitterRate(100, 2)
+		assert.True(r > 0 && r < 200, "%v", r)
+	}
+}
+
+func TestWouldLog(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		lg     = newTestLogger("wouldlog")
+	)
+
+	lg.SetLevel(INFO)
+	lg.SetAppender(&null{})
+	assert.True(lg.WouldLog(INFO))
+	assert.False(lg.WouldLog(DEBUG))
+
+	lg.SetAppender(nil, WARN)
+	assert.False(lg.WouldLog(WARN))
+	assert.True(lg.WouldLog(ERROR))
+
+	lg.SetRatelimit(1, ERROR)
+	assert.True(lg.WouldLog(ERROR))
+	assert.True(lg.WouldLog(ERROR), "WouldLog should not consume the rate limit")
+	lg.Error("consume")
+	assert.False(lg.WouldLog(ERROR))
+	assert.True(lg.WouldLog(FATAL))
+}
+
+func TestSetAppenderFunc(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		calls  = make(map[Level]int)
+		errapp = &dap{}
+		others = &dap{}
+		parent = newTestLogger("appenderfunc")
+		child  = parent.New("child")
+	)
+
+	parent.SetLevel(TRACE)
+	parent.SetFormat("%m")
+	parent.SetAppenderFunc(func(level Level) Appender {
+		calls[level]++
+		if level <= ERROR {
+			return errapp
+		}
+		return others
+	})
+	for _, level := range Levels() {
+		assert.Equal(1, calls[level], LevelsToString[level])
+	}
+
+	for _, lg := range []Logger{parent, child} {
+		lg.Error("error")
+		lg.Info("info")
+		assert.Equal("error\n", errapp.d)
+		assert.Equal("info\n", others.d)
+	}
+
+	// the child detached from the appenders keeps its own
+	child.SetAppender(others)
+	parent.SetAppenderFunc(func(Level) Appender { return errapp })
+	child.Error("child")
+	assert.Equal("child\n", others.d)
+}
+
+func TestConcurrentPropagation(t *testing.T) {
+	var (
+		assert       = assert.New(t)
+		root, leaves = newTree("propagation")
+		wg           sync.WaitGroup
+		added        = make([]Logger, 100)
+	)
+
+	for g := 0; g < 2; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				root.SetLevel(Level((g + i) % 6))
+			}
+		}(g)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range added {
+			added[i] = leaves[i*7%len(leaves)].New("added")
+		}
+	}()
+	wg.Wait()
+
+	level := root.Level()
+	for _, l := range append(leaves, added...) {
+		assert.Equal(level, l.Level())
+	}
+}
+
+func TestPropagationPerTree(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		root   = newTestLogger("tree").(*logger)
+		child  = root.New("child").(*logger)
+		clone  = root.Clone().(*logger)
+	)
+	assert.True(root.tree == child.tree)
+
+	// the reconfigurations of the other trees are not blocked by the tree
+	root.tree.Lock()
+	defer root.tree.Unlock()
+	done := make(chan struct{})
+	go func() {
+		clone.SetLevel(ERROR)
+		clone.New("child").SetLevel(INFO)
+		newTestLogger("other").SetLevel(ERROR)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the reconfiguration of the other trees is blocked")
+	}
+	assert.Equal(ERROR, clone.Level())
+}
+
+// newTree returns a tree of loggers with 1+10+100+1000 nodes and its leaves.
+func newTree(name string) (Logger, []Logger) {
+	var (
+		root   = New(name)
+		leaves []Logger
+	)
+	for i := 0; i < 10; i++ {
+		l1 := root.New(strconv.Itoa(i))
+		for j := 0; j < 10; j++ {
+			l2 := l1.New(strconv.Itoa(j))
+			for k := 0; k < 10; k++ {
+				leaves = append(leaves, l2.New(strconv.Itoa(k)))
+			}
+		}
+	}
+	return root, leaves
+}
+
+func BenchmarkReconfigureTree(b *testing.B) {
+	root, _ := newTree("bench-tree")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		root.SetLevel(Level(i % 6))
+	}
+}
+
+// BenchmarkNewWhileReconfiguring looks up a child of the root by New while
+// the tree is reconfigured continuously.
+func BenchmarkNewWhileReconfiguring(b *testing.B) {
+	var (
+		root, _ = newTree("bench-tree-contended")
+		stop    = make(chan struct{})
+		done    = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				root.SetLevel(Level(i % 6))
+			}
+		}
+	}()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		root.New(strconv.Itoa(i % 10))
+	}
+	b.StopTimer()
+	close(stop)
+	<-done
+}
+
+func TestSetStrictAppender(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		buf    = bytes.NewBuffer(nil)
+		d      = &dap{}
+		lg     = newTestLogger("strict")
+	)
+	stderr = buf
+	defer func() { stderr = os.Stderr }()
+
+	lg.SetAppender(nil)
+	lg.SetAppender(d, ERROR)
+	lg.Info("dropped silently")
+	assert.Empty(buf.String())
+
+	SetStrictAppender(true)
+	defer SetStrictAppender(false)
+	lg.Info("a")
+	lg.Warn("b")
+	lg.Info("c")
+	assert.Equal("log: logger \"strict\" has no appender for INFO, the logs are dropped\n", buf.String())
+	lg.Error("d")
+	assert.Equal("d", strings.TrimSpace(d.d[strings.LastIndexByte(d.d, ' '):]))
+}
+
+func TestWithLevel(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		parent = newTestLogger("withlevel")
+		child  = parent.New("child")
+	)
+	parent.SetLevel(INFO)
+
+	restore := child.WithLevel(TRACE)
+	assert.Equal(TRACE, child.Level())
+	inner := child.WithLevel(WARN)
+	assert.Equal(WARN, child.Level())
+	inner()
+	assert.Equal(TRACE, child.Level())
+	parent.SetLevel(ERROR)
+	assert.Equal(TRACE, child.Level(), "detached in the scope")
+	restore()
+	assert.Equal(INFO, child.Level())
+	parent.SetLevel(DEBUG)
+	assert.Equal(DEBUG, child.Level(), "inherits again after restoring")
+
+	child.SetLevel(WARN)
+	child.WithLevel(TRACE)()
+	parent.SetLevel(INFO)
+	assert.Equal(WARN, child.Level(), "keeps detached")
+}
+
+func TestBindLevel(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		parent = newTestLogger("bindlevel")
+		child  = parent.New("child")
+		level  = int32(INFO)
+	)
+
+	parent.SetAppender(d)
+	parent.SetFormat("%m")
+	parent.BindLevel(&level)
+	child.Debug("a")
+	assert.Empty(d.d)
+	atomic.StoreInt32(&level, int32(DEBUG))
+	assert.Equal(DEBUG, child.Level())
+	child.Debug("b")
+	assert.Equal("b\n", d.d)
+	atomic.StoreInt32(&level, int32(ERROR))
+	assert.False(child.WouldLog(WARN))
+
+	restore := child.WithLevel(TRACE)
+	child.Debug("c")
+	assert.Equal("c\n", d.d)
+	restore()
+	assert.Equal(ERROR, child.Level(), "bound again after restoring")
+
+	parent.SetLevel(TRACE)
+	atomic.StoreInt32(&level, int32(ERROR))
+	child.Debug("d")
+	assert.Equal("d\n", d.d, "unbound by SetLevel")
+}
+
+func TestIndexedArguments(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("indexed")
+	)
+
+	lg.SetAppender(d)
+	lg.SetFormat("%m")
+	lg.Infof("%[2]d-%[1]d", 1, 2)
+	assert.Equal("2-1\n", d.d)
+	lg.Infof("%[1]s %[1]q %[3]*[2]d|", "a", 7, 3)
+	assert.Equal("a \"a\"   7|\n", d.d)
+	lg.WithFields(Field{"k", "v"}).Infof("%[2]s %[1]v", errors.New("failed"), "op")
+	assert.Equal("op failed k=v\n", d.d)
+	lg.Infof("%[3]d", 1)
+	assert.Equal("%!d(BADINDEX)\n", d.d)
+
+	lg.SetErrorChain(true)
+	lg.Infof("%[2]s: %[1]v", &opaqueError{"outer", errors.New("inner")}, "op")
+	assert.Equal("op: outer: inner\n", d.d)
+}
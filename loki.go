@@ -0,0 +1,91 @@
+package log
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// LokiBatchSize is the number of the logs of a batch pushed by the
+	// appender returned by NewLokiAppender.
+	LokiBatchSize = 100
+	// LokiFlushInterval is the interval of pushing the buffered logs by the
+	// appender returned by NewLokiAppender.
+	LokiFlushInterval = time.Second
+)
+
+// NewLokiAppender returns an HTTPAppender which pushes the logs to the push
+// API of Grafana Loki, e.g. "http://localhost:3100/loki/api/v1/push". The
+// logs of a batch are grouped into the streams by their log-levels, every
+// stream has the labels and the "level" label of the lowercase log-level,
+// the lines are the logs formatted by the format of the logger, like:
+//
+//	{"streams":[{"stream":{"app":"api","level":"info"},"values":[["1609459200000000000","..."]]}]}
+func NewLokiAppender(url string, labels map[string]string, opts ...HTTPOption) *HTTPAppender {
+	var (
+		streams = make(map[Level][]byte, len(LevelsToString))
+		keys    = make([]string, 0, len(labels))
+	)
+	for k := range labels {
+		if k != "level" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for level, name := range LevelsToString {
+		b := []byte(`{"stream":{`)
+		for _, k := range keys {
+			b = appendJSONQuote(b, k)
+			b = append(b, ':')
+			b = appendJSONQuote(b, labels[k])
+			b = append(b, ',')
+		}
+		b = append(b, `"level":`...)
+		b = appendJSONQuote(b, strings.ToLower(name))
+		streams[level] = append(b, `},"values":[`...)
+	}
+
+	a := newHTTPAppender(url, LokiBatchSize, LokiFlushInterval, opts, "application/json",
+		func(b []byte, r *Record) []byte {
+			b = append(b, `["`...)
+			b = strconv.AppendInt(b, r.Time.UnixNano(), 10)
+			b = append(b, `",`...)
+			line := r.Data
+			if n := len(line); n > 0 && line[n-1] == '\n' {
+				line = line[:n-1]
+			}
+			b = appendJSONQuote(b, b2s(line))
+			return append(b, ']')
+		},
+		func(b []byte, items []httpitem) []byte {
+			b = append(b, `{"streams":[`...)
+			first := true
+			for level := FATAL; level <= TRACE; level++ {
+				n := 0
+				for _, item := range items {
+					if item.level != level {
+						continue
+					}
+					if n == 0 {
+						if !first {
+							b = append(b, ',')
+						}
+						b = append(b, streams[level]...)
+						first = false
+					} else {
+						b = append(b, ',')
+					}
+					b = append(b, item.data...)
+					n++
+				}
+				if n != 0 {
+					b = append(b, ']', '}')
+				}
+			}
+			return append(b, ']', '}')
+		})
+	a.caller = false // the lines are formatted by the logger
+	return a
+}
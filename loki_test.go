@@ -0,0 +1,76 @@
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type lokipush struct {
+	Streams []struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	} `json:"streams"`
+}
+
+func TestLokiAppender(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		mu     sync.Mutex
+		pushes []lokipush
+		ctypes []string
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var p lokipush
+			b, _ := ioutil.ReadAll(r.Body)
+			if err := json.Unmarshal(b, &p); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			mu.Lock()
+			pushes = append(pushes, p)
+			ctypes = append(ctypes, r.Header.Get("Content-Type"))
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		app   = NewLokiAppender(server.URL, map[string]string{"app": "api", "env": "prod", "level": "x"})
+		lg    = newTestLogger("loki")
+		start = time.Now()
+	)
+	defer server.Close()
+	defer app.Close()
+
+	lg.SetAppender(app)
+	lg.SetFormat("[%l] %m")
+	lg.Info("a")
+	lg.WithFields(Field{"k", "v"}).Error("b\n\"c\"")
+	lg.Info("d")
+	assert.Nil(app.Flush())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !assert.Len(pushes, 1) {
+		return
+	}
+	assert.Equal("application/json", ctypes[0])
+	streams := pushes[0].Streams
+	if !assert.Len(streams, 2) {
+		return
+	}
+	assert.Equal(map[string]string{"app": "api", "env": "prod", "level": "error"}, streams[0].Stream)
+	assert.Equal(map[string]string{"app": "api", "env": "prod", "level": "info"}, streams[1].Stream)
+	assert.Len(streams[0].Values, 1)
+	assert.Equal("[ERROR] b\n\"c\" k=v", streams[0].Values[0][1])
+	assert.Len(streams[1].Values, 2)
+	assert.Equal("[INFO] a", streams[1].Values[0][1])
+	assert.Equal("[INFO] d", streams[1].Values[1][1])
+	ts, err := strconv.ParseInt(streams[1].Values[0][0], 10, 64)
+	assert.NoError(err)
+	assert.True(ts >= start.UnixNano() && ts <= time.Now().UnixNano(), ts)
+}
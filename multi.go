@@ -0,0 +1,136 @@
+package log
+
+import (
+	"reflect"
+	"time"
+)
+
+// MultiAppender is an Appender which outputs every log to all of its
+// appenders in order, see Logger.AddAppender.
+type MultiAppender struct {
+	apps []Appender
+}
+
+// multiRecordAppender is the MultiAppender which has the RecordAppender,
+// the Record is passed to the RecordAppenders and its Data to the others.
+type multiRecordAppender struct {
+	*MultiAppender
+}
+
+// NewMultiAppender returns an appender which outputs every log to all the
+// appenders, the nil appenders are skipped and the MultiAppenders are
+// flattened. It returns the appender itself if there is only one, and nil
+// if there is none.
+func NewMultiAppender(appenders ...Appender) Appender {
+	var (
+		apps   []Appender
+		record bool
+	)
+	for _, app := range appenders {
+		switch a := app.(type) {
+		case nil:
+			continue
+		case *MultiAppender:
+			apps = append(apps, a.apps...)
+		case *multiRecordAppender:
+			apps = append(apps, a.apps...)
+		default:
+			apps = append(apps, a)
+		}
+	}
+	for _, app := range apps {
+		if _, ok := app.(RecordAppender); ok {
+			record = true
+		}
+	}
+	switch {
+	case len(apps) == 0:
+		return nil
+	case len(apps) == 1:
+		return apps[0]
+	case record:
+		return &multiRecordAppender{&MultiAppender{apps: apps}}
+	}
+	return &MultiAppender{apps: apps}
+}
+
+// removeAppender returns the appender app without the target, which is app
+// itself or one of the appenders of the MultiAppender app.
+func removeAppender(app, target Appender) Appender {
+	var apps []Appender
+	switch a := app.(type) {
+	case *MultiAppender:
+		apps = a.apps
+	case *multiRecordAppender:
+		apps = a.apps
+	default:
+		if sameAppender(app, target) {
+			return nil
+		}
+		return app
+	}
+	rest := make([]Appender, 0, len(apps))
+	for _, a := range apps {
+		if !sameAppender(a, target) {
+			rest = append(rest, a)
+		}
+	}
+	if len(rest) == len(apps) {
+		return app
+	}
+	return NewMultiAppender(rest...)
+}
+
+// sameAppender reports whether a and b are the same appender, the
+// appenders of the incomparable types are never the same.
+func sameAppender(a, b Appender) bool {
+	if a == nil || b == nil || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// Appenders returns the appenders of the MultiAppender.
+func (a *MultiAppender) Appenders() []Appender {
+	return append([]Appender(nil), a.apps...)
+}
+
+func (a *MultiAppender) Output(level Level, t time.Time, data []byte) {
+	for _, app := range a.apps {
+		app.Output(level, t, data)
+	}
+}
+
+func (a *multiRecordAppender) OutputRecord(r *Record) {
+	for _, app := range a.apps {
+		if rapp, ok := app.(RecordAppender); ok {
+			rapp.OutputRecord(r)
+		} else {
+			app.Output(r.Level, r.Time, r.Data)
+		}
+	}
+}
+
+// NeedsCaller reports whether or not any of the RecordAppenders reads the
+// caller, see CallerAppender.
+func (a *multiRecordAppender) NeedsCaller() bool {
+	for _, app := range a.apps {
+		if _, ok := app.(RecordAppender); ok && needsCaller(app) {
+			return true
+		}
+	}
+	return false
+}
+
+// Flush flushes all the appenders, it returns the first error.
+func (a *MultiAppender) Flush() error {
+	var err error
+	for _, app := range a.apps {
+		if f, ok := app.(Flusher); ok {
+			if e := f.Flush(); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,97 @@
+package log
+
+import (
+	"bytes"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddAppender(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d0     = &dap{}
+		d1     = &dap{}
+		r      = &recordap{}
+		lg     = newTestLogger("addappender")
+	)
+
+	lg.SetFormat("[%l] %m")
+	lg.ReplaceAllAppenders(d0)
+	lg.AddAppender(d1, ERROR)
+	lg.Info("a")
+	assert.Equal("[INFO] a\n", d0.d)
+	assert.Empty(d1.d, "only added to ERROR")
+	lg.Error("b")
+	assert.Equal("[ERROR] b\n", d0.d)
+	assert.Equal("[ERROR] b\n", d1.d)
+
+	lg.AddAppender(r)
+	lg.WithFields(Field{"k", 1}).Error("c")
+	assert.Equal("[ERROR] c k=1\n", d0.d)
+	assert.Equal("[ERROR] c k=1\n", d1.d)
+	assert.Equal("c", string(r.r.Message))
+	lg.Warn("d")
+	assert.Equal("[WARN] d\n", d0.d)
+	assert.Equal("[WARN] d\n", r.data)
+
+	// SetAppender without levels replaces all the appenders.
+	lg.SetAppender(d1)
+	lg.Error("e")
+	lg.Info("f")
+	assert.Equal("[WARN] d\n", d0.d)
+	assert.Equal("[INFO] f\n", d1.d)
+	assert.Equal("[WARN] d\n", r.data)
+}
+
+func TestNamedAppender(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d0     = &dap{}
+		d1     = &dap{}
+		d2     = &dap{}
+		lg     = newTestLogger("namedappender")
+		child  = lg.New("child")
+	)
+
+	lg.SetFormat("[%l] %m")
+	lg.ReplaceAllAppenders(d0)
+	lg.AddNamedAppender("debug", d1, ERROR)
+	child.Error("a")
+	assert.Equal("[ERROR] a\n", d0.d)
+	assert.Equal("[ERROR] a\n", d1.d)
+
+	// the same name replaces the previous one
+	lg.AddNamedAppender("debug", d2)
+	child.Error("b")
+	child.Info("c")
+	assert.Equal("[INFO] c\n", d0.d)
+	assert.Equal("[ERROR] a\n", d1.d)
+	assert.Equal("[INFO] c\n", d2.d)
+
+	lg.RemoveAppender("debug")
+	lg.RemoveAppender("none")
+	child.Error("d")
+	assert.Equal("[ERROR] d\n", d0.d)
+	assert.Equal("[INFO] c\n", d2.d)
+	m := (*meta)(atomic.LoadPointer(&lg.(*logger).meta))
+	_, multi := m.appenders[ERROR].(*MultiAppender)
+	assert.False(multi)
+}
+
+func TestNewMultiAppender(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		buf    = bytes.NewBuffer(nil)
+		w      = NewWriterAppender(buf)
+	)
+	assert.Nil(NewMultiAppender())
+	assert.Nil(NewMultiAppender(nil, nil))
+	assert.True(d == NewMultiAppender(nil, d))
+
+	m := NewMultiAppender(NewMultiAppender(d, w), w).(*MultiAppender)
+	assert.Equal([]Appender{d, w, w}, m.Appenders())
+	assert.Nil(m.Flush())
+}
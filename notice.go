@@ -0,0 +1,49 @@
+package log
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+func (l *logger) SetRatelimitNotice(interval time.Duration) {
+	l.l.Lock()
+	defer l.l.Unlock()
+	if l.notice != nil {
+		close(l.notice)
+		l.notice = nil
+	}
+	if interval > 0 {
+		l.notice = make(chan struct{})
+		go l.noticeLoop(interval, l.notice)
+	}
+}
+
+func (l *logger) noticeLoop(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.emitNotices()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// emitNotices emits the notices of the logs dropped by the rate limit of the
+// logger and its children.
+func (l *logger) emitNotices() {
+	for level := FATAL; level <= TRACE; level++ {
+		if n := atomic.SwapUint64(&l.drops[level], 0); n != 0 {
+			l.dolog(&entry{logger: l, unlimited: true}, "rate limit dropped %d messages at %s",
+				WARN, n, LevelsToString[level])
+		}
+	}
+	l.l.Lock()
+	children := l.children
+	l.l.Unlock()
+	for _, child := range children {
+		child.emitNotices()
+	}
+}
@@ -0,0 +1,81 @@
+package log
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type syncap struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (a *syncap) Output(level Level, t time.Time, data []byte) {
+	a.mu.Lock()
+	a.lines = append(a.lines, string(data))
+	a.mu.Unlock()
+}
+
+func (a *syncap) snapshot() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.lines...)
+}
+
+func TestSetRatelimitNotice(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		a      = &syncap{}
+		lg     = newTestLogger("notice")
+		child  = lg.New("child")
+	)
+
+	lg.SetAppender(a)
+	lg.SetFormat("[%l] %m")
+	lg.SetRatelimit(1, INFO)
+	lg.SetRatelimit(1, ERROR)
+	lg.SetRatelimitNotice(10 * time.Millisecond)
+	defer lg.SetRatelimitNotice(0)
+
+	for i := 0; i < 10; i++ {
+		lg.Info("info")
+	}
+	for i := 0; i < 3; i++ {
+		child.Error("error")
+	}
+	assert.Eventually(func() bool { return len(a.snapshot()) == 4 }, time.Second, time.Millisecond)
+	assert.Equal([]string{
+		"[INFO] info\n",
+		"[ERROR] error\n",
+		"[WARN] rate limit dropped 9 messages at INFO\n",
+		"[WARN] rate limit dropped 2 messages at ERROR\n",
+	}, a.snapshot())
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Len(a.snapshot(), 4, "no more notice without drops")
+}
+
+func TestGlobalSetRatelimitNotice(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		a      = &syncap{}
+	)
+
+	setGlobal(t, "[%l] %m", a)
+	SetRatelimit(1, INFO)
+	defer SetRatelimit(0, INFO)
+	SetRatelimitNotice(10 * time.Millisecond)
+	defer SetRatelimitNotice(0)
+
+	for i := 0; i < 3; i++ {
+		Info("info")
+	}
+	assert.Eventually(func() bool { return len(a.snapshot()) == 2 }, time.Second, time.Millisecond)
+	assert.Equal([]string{
+		"[INFO] info\n",
+		"[WARN] rate limit dropped 2 messages at INFO\n",
+	}, a.snapshot())
+}
@@ -0,0 +1,118 @@
+package log
+
+import (
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/lrita/cache"
+)
+
+// PoolMaxBufferSize is the max capacity of the buffer retained by the buffer
+// pool, the larger buffers grown by the large logs are dropped instead of
+// being put back, so that they can be collected by GC.
+var PoolMaxBufferSize = 64 << 10
+
+// pool is the pool of the buffers used to format the logs, its actual type
+// is *cache.BufCache.
+var pool = unsafe.Pointer(newpool())
+
+func newpool() *cache.BufCache {
+	return &cache.BufCache{
+		New: func() []byte {
+			if atomic.LoadUint32(&poolstats) != 0 {
+				atomic.AddUint64(&poolstat.Misses, 1)
+			}
+			return make([]byte, 256)
+		},
+		Size: 256,
+	}
+}
+
+// PoolStat is the statistics of the buffer pool, see PoolStats.
+type PoolStat struct {
+	Gets      uint64 // the buffers got from the pool
+	Puts      uint64 // the buffers put back to the pool
+	Misses    uint64 // the gets allocating the new buffers
+	Discarded uint64 // the oversized buffers dropped, see PoolMaxBufferSize
+}
+
+var (
+	poolstats uint32 // counts the poolstat if it is not 0
+	poolstat  PoolStat
+)
+
+// SetPoolStats set whether or not to count the statistics of the buffer pool
+// returned by PoolStats, which helps to tune the PoolMaxBufferSize, e.g. the
+// many discarded buffers mean the logs are often larger than it. It is
+// disabled by default, because the counting costs for every log.
+func SetPoolStats(enable bool) {
+	var v uint32
+	if enable {
+		v = 1
+	}
+	atomic.StoreUint32(&poolstats, v)
+}
+
+// PoolStats returns the statistics of the buffer pool counted since
+// SetPoolStats enabled it.
+func PoolStats() PoolStat {
+	return PoolStat{
+		Gets:      atomic.LoadUint64(&poolstat.Gets),
+		Puts:      atomic.LoadUint64(&poolstat.Puts),
+		Misses:    atomic.LoadUint64(&poolstat.Misses),
+		Discarded: atomic.LoadUint64(&poolstat.Discarded),
+	}
+}
+
+// nopool disables the buffer pool if it is not 0, see SetPoolEnabled.
+var nopool uint32
+
+// SetPoolEnabled set whether or not to reuse the buffers of the logs by the
+// buffer pool, it is enabled by default. The data passed to Appender.Output
+// is only valid during the invoking, the appender must copy it if it retains
+// the data, otherwise the retained data is overwritten by the later logs
+// reusing the buffer. Disabling the pool allocates a new buffer for every
+// log, which makes such bugs of the appenders reproducible for debugging.
+func SetPoolEnabled(enable bool) {
+	var v uint32
+	if !enable {
+		v = 1
+	}
+	atomic.StoreUint32(&nopool, v)
+}
+
+// getbuf returns an empty buffer from the pool.
+func getbuf() []byte {
+	if atomic.LoadUint32(&poolstats) != 0 {
+		atomic.AddUint64(&poolstat.Gets, 1)
+	}
+	if atomic.LoadUint32(&nopool) != 0 {
+		if atomic.LoadUint32(&poolstats) != 0 {
+			atomic.AddUint64(&poolstat.Misses, 1)
+		}
+		return make([]byte, 0, 256)
+	}
+	return (*cache.BufCache)(atomic.LoadPointer(&pool)).Get()[:0]
+}
+
+// putbuf puts the buffer back to the pool unless it is oversized.
+func putbuf(b []byte) {
+	if atomic.LoadUint32(&nopool) != 0 {
+		return
+	} else if cap(b) > PoolMaxBufferSize {
+		if atomic.LoadUint32(&poolstats) != 0 {
+			atomic.AddUint64(&poolstat.Discarded, 1)
+		}
+		return
+	}
+	if atomic.LoadUint32(&poolstats) != 0 {
+		atomic.AddUint64(&poolstat.Puts, 1)
+	}
+	(*cache.BufCache)(atomic.LoadPointer(&pool)).Put(b[:cap(b)])
+}
+
+// TrimPool drops all the buffers retained by the buffer pool, which is
+// useful to reduce the memory after a burst of logs.
+func TrimPool() {
+	atomic.StorePointer(&pool, unsafe.Pointer(newpool()))
+}
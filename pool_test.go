@@ -0,0 +1,80 @@
+package log
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrimPool(t *testing.T) {
+	assert := assert.New(t)
+	defer TrimPool()
+
+	putbuf(make([]byte, PoolMaxBufferSize+1))
+	for i := 0; i < 16; i++ {
+		b := getbuf()
+		assert.Equal(0, len(b))
+		assert.True(cap(b) <= PoolMaxBufferSize, cap(b))
+	}
+
+	for i := 0; i < 16; i++ {
+		putbuf(make([]byte, 4096))
+	}
+	TrimPool()
+	for i := 0; i < 16; i++ {
+		assert.Equal(256, cap(getbuf()))
+	}
+}
+
+// retainap retains the data passed to Output without copying, which is the
+// bug of the appender SetPoolEnabled helps to find.
+type retainap struct {
+	data [][]byte
+}
+
+func (a *retainap) Output(level Level, t time.Time, data []byte) {
+	a.data = append(a.data, data)
+}
+
+func TestSetPoolEnabled(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		a      = &retainap{}
+		lg     = newTestLogger("nopool")
+	)
+
+	SetPoolEnabled(false)
+	defer SetPoolEnabled(true)
+	lg.SetAppender(a)
+	lg.SetFormat("%m")
+	for i := 0; i < 16; i++ {
+		lg.Infof("log %d", i)
+	}
+	for i, data := range a.data {
+		assert.Equal(fmt.Sprintf("log %d\n", i), string(data))
+	}
+}
+
+func TestPoolStats(t *testing.T) {
+	assert := assert.New(t)
+	defer TrimPool()
+
+	before := PoolStats()
+	putbuf(getbuf())
+	assert.Equal(before, PoolStats(), "disabled by default")
+
+	SetPoolStats(true)
+	defer SetPoolStats(false)
+	TrimPool()
+	before = PoolStats()
+	b := getbuf()
+	s := PoolStats()
+	assert.Equal(before.Gets+1, s.Gets)
+	assert.Equal(before.Misses+1, s.Misses, "the new pool is empty")
+	putbuf(b)
+	assert.Equal(before.Puts+1, PoolStats().Puts)
+	putbuf(make([]byte, PoolMaxBufferSize+1))
+	assert.Equal(before.Discarded+1, PoolStats().Discarded)
+}
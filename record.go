@@ -0,0 +1,528 @@
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// Field is a key-value pair attached to the log.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F returns the Field of the key and value, it is the shorthand for the
+// fields of the XxxKV, e.g. InfoKV("done", F("took", d)).
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// DurationNumeric decides whether the time.Duration values of the fields are
+// output as the integer nanoseconds instead of the string like "1.5s",
+// which is the default.
+var DurationNumeric = false
+
+// Duration returns the Field of the duration, which is output like "1.5s" or
+// the integer nanoseconds, see DurationNumeric.
+func Duration(key string, d time.Duration) Field {
+	return Field{Key: key, Value: d}
+}
+
+// Time returns the Field of the time, which is output in the RFC3339 format
+// with the nanoseconds, e.g. "2006-01-02T15:04:05.999999999Z07:00".
+func Time(key string, t time.Time) Field {
+	return Field{Key: key, Value: t}
+}
+
+// statics is the static fields set by SetStaticFields, they are rendered
+// once when set.
+type statics struct {
+	fields []Field
+	text   []byte // rendered for %S
+}
+
+var (
+	staticfields atomic.Value // *statics
+	nostatics    = &statics{}
+)
+
+// SetStaticFields set the static fields like the labels of the deployment,
+// e.g. {"env": "prod", "region": "us-east"}, which are the same for all the
+// logs of the process. They are rendered once here for %S of the format, and
+// attached to the fields of the Record passed to the RecordAppenders.
+func SetStaticFields(fields map[string]string) {
+	s := &statics{fields: make([]Field, 0, len(fields))}
+	for k, v := range fields {
+		s.fields = append(s.fields, Field{Key: k, Value: v})
+	}
+	sort.Slice(s.fields, func(i, j int) bool { return s.fields[i].Key < s.fields[j].Key })
+	if len(s.fields) != 0 {
+		s.text = appendFields(nil, s.fields, false)[1:] // trim the leading ' '
+	}
+	staticfields.Store(s)
+}
+
+func loadStatics() *statics {
+	s, _ := staticfields.Load().(*statics)
+	if s == nil {
+		return nostatics
+	}
+	return s
+}
+
+// with returns the static fields followed by the fields of the log.
+func (s *statics) with(fields []Field) []Field {
+	if len(fields) == 0 {
+		return s.fields
+	}
+	ff := make([]Field, 0, len(s.fields)+len(fields))
+	ff = append(ff, s.fields...)
+	return append(ff, fields...)
+}
+
+// Record is a structured log, which is passed to the RecordAppender. Like
+// the data passed to Appender.Output, the slices of Record are only valid
+// during the OutputRecord invoking, if you want do something async with
+// them, you need copy them yourself.
+type Record struct {
+	Level Level
+	Time  time.Time
+	// Message is the log message formatted with `fmt.Sprintf` or `fmt.Sprint`.
+	Message []byte
+	// Caller and Line are the caller with full file path and its line
+	// number. They are empty if the log-level does not resolve the caller,
+	// see Logger.SetCallerMinLevel.
+	Caller string
+	Line   int
+	// PC is the program counter of the caller, which resolves the function
+	// of the caller by runtime.FuncForPC. It is 0 if the caller is empty.
+	PC     uintptr
+	Fields []Field
+	// Data is the log formatted by the format of the log-level, which is
+	// the same with the data passed to Appender.Output.
+	Data []byte
+}
+
+// RecordAppender is an Appender which accepts the structured Record. The
+// Logger outputs the Record instead of the formatted data to the appenders
+// implementing it.
+type RecordAppender interface {
+	Appender
+	OutputRecord(r *Record)
+}
+
+// CallerAppender is a RecordAppender which tells whether or not it reads the
+// caller of the Record. The Logger resolves the caller by `runtime.Caller`
+// for the RecordAppenders which do not implement it, and only for the ones
+// which need it otherwise.
+type CallerAppender interface {
+	RecordAppender
+	NeedsCaller() bool
+}
+
+// needsCaller reports whether or not the appender reads the caller of the
+// Record, see CallerAppender.
+func needsCaller(app Appender) bool {
+	if c, ok := app.(CallerAppender); ok {
+		return c.NeedsCaller()
+	}
+	return true
+}
+
+var (
+	// ErrorKey is the key of the error attached by Logger.WithError.
+	ErrorKey = "error"
+	// ErrorTypeKey is the key of the type of the error attached by
+	// Logger.WithError, the type is not attached if it is empty, which is
+	// the default.
+	ErrorTypeKey = ""
+)
+
+// errorFields returns the fields of the error attached by WithError.
+func errorFields(err error) []Field {
+	if ErrorTypeKey == "" {
+		return []Field{{ErrorKey, err}}
+	}
+	return []Field{{ErrorKey, err}, {ErrorTypeKey, fmt.Sprintf("%T", err)}}
+}
+
+// entry is a log handler which attaches the fields and the call site to all
+// the logs emitted by it, its configuration is shared with the underlying
+// logger.
+type entry struct {
+	*logger
+	fields    []Field
+	site      *CallSite
+	skip      int       // added to the calldepth of the logger
+	exitcode  *int      // the exit code of the fatal log, see FatalWithCode
+	unlimited bool      // the log is not rate limited, see SetRatelimitNotice
+	at        time.Time // the time of the log instead of now, see InfoAt
+}
+
+func (e *entry) New(name string) Logger {
+	return &entry{logger: e.logger.New(name).(*logger), fields: e.fields}
+}
+
+func (e *entry) WithFields(fields ...Field) Logger {
+	ff := make([]Field, 0, len(e.fields)+len(fields))
+	ff = append(ff, e.fields...)
+	ff = append(ff, fields...)
+	return &entry{logger: e.logger, fields: ff, site: e.site, skip: e.skip}
+}
+
+func (e *entry) WithError(err error) Logger {
+	if err == nil {
+		return e
+	}
+	return e.WithFields(errorFields(err)...)
+}
+
+func (e *entry) AtSite(site *CallSite) Logger {
+	return &entry{logger: e.logger, fields: e.fields, site: site, skip: e.skip}
+}
+
+func (e *entry) SetCallDepth(d int) {
+	e.logger.SetCallDepth(d - e.skip)
+}
+
+func (e *entry) Fatal(v ...interface{}) {
+	e.dolog(e, "", FATAL, v...)
+}
+
+func (e *entry) FatalWithCode(code int, v ...interface{}) {
+	ee := *e
+	ee.exitcode = &code
+	e.dolog(&ee, "", FATAL, v...)
+}
+
+func (e *entry) Error(v ...interface{}) {
+	e.dolog(e, "", ERROR, v...)
+}
+
+func (e *entry) Info(v ...interface{}) {
+	e.dolog(e, "", INFO, v...)
+}
+
+func (e *entry) Warn(v ...interface{}) {
+	e.dolog(e, "", WARN, v...)
+}
+
+func (e *entry) Debug(v ...interface{}) {
+	e.dolog(e, "", DEBUG, v...)
+}
+
+func (e *entry) Trace(v ...interface{}) {
+	e.dolog(e, "", TRACE, v...)
+}
+
+func (e *entry) Fatalf(fmt string, v ...interface{}) {
+	e.dolog(e, fmt, FATAL, v...)
+}
+
+func (e *entry) Errorf(fmt string, v ...interface{}) {
+	e.dolog(e, fmt, ERROR, v...)
+}
+
+func (e *entry) Infof(fmt string, v ...interface{}) {
+	e.dolog(e, fmt, INFO, v...)
+}
+
+func (e *entry) Warnf(fmt string, v ...interface{}) {
+	e.dolog(e, fmt, WARN, v...)
+}
+
+func (e *entry) Debugf(fmt string, v ...interface{}) {
+	e.dolog(e, fmt, DEBUG, v...)
+}
+
+func (e *entry) Tracef(fmt string, v ...interface{}) {
+	e.dolog(e, fmt, TRACE, v...)
+}
+
+// with returns the entry attaching the fields of e and kv, the fields are
+// copied only if both of them are not empty.
+func (e *entry) with(kv []Field) *entry {
+	if len(kv) == 0 {
+		return e
+	}
+	ee := *e
+	if len(e.fields) == 0 {
+		ee.fields = kv
+	} else {
+		ee.fields = make([]Field, 0, len(e.fields)+len(kv))
+		ee.fields = append(ee.fields, e.fields...)
+		ee.fields = append(ee.fields, kv...)
+	}
+	return &ee
+}
+
+func (e *entry) FatalKV(msg string, kv ...Field) {
+	e.dolog(e.with(kv), "", FATAL, msg)
+}
+
+func (e *entry) ErrorKV(msg string, kv ...Field) {
+	e.dolog(e.with(kv), "", ERROR, msg)
+}
+
+func (e *entry) InfoKV(msg string, kv ...Field) {
+	e.dolog(e.with(kv), "", INFO, msg)
+}
+
+func (e *entry) WarnKV(msg string, kv ...Field) {
+	e.dolog(e.with(kv), "", WARN, msg)
+}
+
+func (e *entry) DebugKV(msg string, kv ...Field) {
+	e.dolog(e.with(kv), "", DEBUG, msg)
+}
+
+func (e *entry) TraceKV(msg string, kv ...Field) {
+	e.dolog(e.with(kv), "", TRACE, msg)
+}
+
+func (e *entry) FatalAt(t time.Time, v ...interface{}) {
+	ee := *e
+	ee.at = t
+	e.dolog(&ee, "", FATAL, v...)
+}
+
+func (e *entry) ErrorAt(t time.Time, v ...interface{}) {
+	ee := *e
+	ee.at = t
+	e.dolog(&ee, "", ERROR, v...)
+}
+
+func (e *entry) InfoAt(t time.Time, v ...interface{}) {
+	ee := *e
+	ee.at = t
+	e.dolog(&ee, "", INFO, v...)
+}
+
+func (e *entry) WarnAt(t time.Time, v ...interface{}) {
+	ee := *e
+	ee.at = t
+	e.dolog(&ee, "", WARN, v...)
+}
+
+func (e *entry) DebugAt(t time.Time, v ...interface{}) {
+	ee := *e
+	ee.at = t
+	e.dolog(&ee, "", DEBUG, v...)
+}
+
+func (e *entry) TraceAt(t time.Time, v ...interface{}) {
+	ee := *e
+	ee.at = t
+	e.dolog(&ee, "", TRACE, v...)
+}
+
+// appendFields appends the fields like " key=value" to b, the value is
+// quoted if it contains spaces, quotes, '=' or control characters.
+// The fields are in the order of keys, see SetFieldOrder. The errors are
+// rendered with their chains if chain, see Logger.SetErrorChain.
+func appendFields(b []byte, fields []Field, chain bool) []byte {
+	if len(fields) > 1 {
+		s := sortFields(fields)
+		for _, i := range s.idx {
+			b = appendField(b, &fields[i], chain)
+		}
+		s.release()
+	} else if len(fields) == 1 {
+		b = appendField(b, &fields[0], chain)
+	}
+	return b
+}
+
+func appendField(b []byte, f *Field, chain bool) []byte {
+	b = append(b, ' ')
+	b = append(b, f.Key...)
+	b = append(b, '=')
+	n := len(b)
+	if err, ok := f.Value.(error); ok && chain {
+		b = appendErrorChain(b, err)
+	} else {
+		b = appendValue(b, f.Value)
+	}
+	if needquote(b[n:]) {
+		b = strconv.AppendQuote(b[:n], string(b[n:]))
+	}
+	return b
+}
+
+func needquote(b []byte) bool {
+	if len(b) == 0 {
+		return true
+	}
+	for i := 0; i < len(b); {
+		c := b[i]
+		if c < utf8.RuneSelf {
+			if c <= ' ' || c == '=' || c == '"' || c == 0x7f {
+				return true
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(b[i:])
+		if r == utf8.RuneError {
+			return true
+		}
+		i += size
+	}
+	return false
+}
+
+// appendValue appends the string form of the value to b.
+func appendValue(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(b, "<nil>"...)
+	case string:
+		return append(b, v...)
+	case []byte:
+		return append(b, v...)
+	case bool:
+		return strconv.AppendBool(b, v)
+	case int:
+		return strconv.AppendInt(b, int64(v), 10)
+	case int8:
+		return strconv.AppendInt(b, int64(v), 10)
+	case int16:
+		return strconv.AppendInt(b, int64(v), 10)
+	case int32:
+		return strconv.AppendInt(b, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(b, v, 10)
+	case uint:
+		return strconv.AppendUint(b, uint64(v), 10)
+	case uint8:
+		return strconv.AppendUint(b, uint64(v), 10)
+	case uint16:
+		return strconv.AppendUint(b, uint64(v), 10)
+	case uint32:
+		return strconv.AppendUint(b, uint64(v), 10)
+	case uint64:
+		return strconv.AppendUint(b, v, 10)
+	case float32:
+		return strconv.AppendFloat(b, float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.AppendFloat(b, v, 'g', -1, 64)
+	case time.Time:
+		return v.AppendFormat(b, time.RFC3339Nano)
+	case time.Duration:
+		if DurationNumeric {
+			return strconv.AppendInt(b, int64(v), 10)
+		}
+		return append(b, v.String()...)
+	case error:
+		return append(b, errorString(v)...)
+	case fmt.Stringer:
+		return append(b, stringerString(v)...)
+	}
+	fmt.Fprint((*bufw)(noescape(unsafe.Pointer(&b))), v)
+	return b
+}
+
+// errorString returns the message of the error like fmt, the nil pointer
+// receiver is "<nil>" and the panic of the method is reported instead of
+// crashing the logging.
+func errorString(err error) string {
+	return catchString(err, "Error", err.Error)
+}
+
+// stringerString returns the String of the value like errorString.
+func stringerString(s fmt.Stringer) string {
+	return catchString(s, "String", s.String)
+}
+
+func catchString(v interface{}, method string, fn func() string) (s string) {
+	defer func() {
+		if r := recover(); r != nil {
+			if nilPointer(v) {
+				s = "<nil>"
+			} else {
+				s = fmt.Sprintf("%%!v(PANIC=%s method: %v)", method, r)
+			}
+		}
+	}()
+	return fn()
+}
+
+// nilPointer reports whether v is a nil pointer of any type.
+func nilPointer(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// fieldorder is the ranks of the keys set by SetFieldOrder, its actual type
+// is map[string]int.
+var fieldorder atomic.Value
+
+// SetFieldOrder set the keys which are rendered first in the given order,
+// the other keys follow them in the sorted order. It applies to the fields
+// rendered in the format and by the JSONAppender, e.g. with
+// SetFieldOrder([]string{"request_id"}), the "request_id" always comes first.
+func SetFieldOrder(keys []string) {
+	order := make(map[string]int, len(keys))
+	for i := len(keys) - 1; i >= 0; i-- {
+		order[keys[i]] = i
+	}
+	fieldorder.Store(order)
+}
+
+var sorterpool = sync.Pool{
+	New: func() interface{} { return &fieldsorter{idx: make([]int, 0, 16)} },
+}
+
+// fieldsorter sorts the indexes of fields by the keys without moving the
+// fields, it is pooled to avoid the allocation for every log.
+type fieldsorter struct {
+	fields []Field
+	idx    []int
+	order  map[string]int
+}
+
+// sortFields returns a fieldsorter whose idx are the indexes of the fields
+// in the order of keys, see SetFieldOrder, the fields with the same key keep
+// their order. The caller should call release after using it.
+func sortFields(fields []Field) *fieldsorter {
+	s := sorterpool.Get().(*fieldsorter)
+	s.fields = fields
+	s.order, _ = fieldorder.Load().(map[string]int)
+	s.idx = s.idx[:0]
+	for i := range fields {
+		s.idx = append(s.idx, i)
+	}
+	sort.Stable(s)
+	return s
+}
+
+func (s *fieldsorter) release() {
+	s.fields = nil
+	s.order = nil
+	sorterpool.Put(s)
+}
+
+func (s *fieldsorter) Len() int { return len(s.idx) }
+
+func (s *fieldsorter) Less(i, j int) bool {
+	ki, kj := s.fields[s.idx[i]].Key, s.fields[s.idx[j]].Key
+	if len(s.order) != 0 {
+		ri, iok := s.order[ki]
+		rj, jok := s.order[kj]
+		if iok && jok {
+			return ri < rj
+		} else if iok || jok {
+			return iok
+		}
+	}
+	return ki < kj
+}
+
+func (s *fieldsorter) Swap(i, j int) { s.idx[i], s.idx[j] = s.idx[j], s.idx[i] }
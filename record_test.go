@@ -0,0 +1,356 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordap struct {
+	r    Record
+	data string
+}
+
+func (a *recordap) Output(level Level, t time.Time, data []byte) {
+	panic("legacy path should not be used")
+}
+
+// OutputRecord formats the record like the "[%l] %m" in pattern mode.
+func (a *recordap) OutputRecord(r *Record) {
+	a.r = *r
+	b := append([]byte("["+LevelsToString[r.Level]+"] "), r.Message...)
+	b = appendFields(b, r.Fields, false)
+	a.data = string(append(b, '\n'))
+}
+
+func TestRecordAppender(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		r      = &recordap{}
+		lg0    = newTestLogger("legacy")
+		lg1    = newTestLogger("record")
+	)
+
+	for _, lg := range []Logger{lg0, lg1} {
+		lg.SetLevel(TRACE)
+		lg.SetFormat("[%l] %m")
+	}
+	lg0.SetAppender(d)
+	lg1.SetAppender(r)
+
+	for _, lg := range []Logger{lg0, lg1} {
+		lg.Infof("a %d", 1)
+		lg.WithFields(Field{"k", "v"}, Field{"n", 2}).Error("b")
+	}
+	assert.Equal("[ERROR] b k=v n=2\n", d.d)
+	assert.Equal(d.d, r.data)
+	assert.Equal(d.d, string(r.r.Data))
+	assert.Equal("b", string(r.r.Message))
+	assert.Equal(ERROR, r.r.Level)
+	assert.Equal("record_test.go", filepath.Base(r.r.Caller))
+	assert.NotZero(r.r.Line)
+
+	lg1.SetCallerMinLevel(FATAL)
+	lg1.Error("c")
+	assert.Equal("", r.r.Caller)
+	assert.Equal(0, r.r.Line)
+}
+
+func TestWithFields(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("fields")
+	)
+
+	lg.SetAppender(d)
+	lg.SetFormat("%m")
+	e0 := lg.WithFields(Field{"a", 1})
+	e1 := e0.WithFields(Field{"b", "x y"}, Field{"c", errors.New("failed")})
+
+	e0.Info("m")
+	assert.Equal("m a=1\n", d.d)
+	e1.Infof("%s", "m")
+	assert.Equal("m a=1 b=\"x y\" c=failed\n", d.d)
+	e1.New("child").Warn("m")
+	assert.Equal("m a=1 b=\"x y\" c=failed\n", d.d)
+	e0.Info("m")
+	assert.Equal("m a=1\n", d.d)
+
+	// configuration is shared with the underlying logger
+	lg.SetFormat("[%l] %m")
+	e0.Info("m")
+	assert.Equal("[INFO] m a=1\n", d.d)
+}
+
+func TestSetFieldOrder(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		buf    = bytes.NewBuffer(nil)
+		lg     = newTestLogger("fieldorder")
+		fields = []Field{{"z", 1}, {"b", 2}, {"request_id", 3}, {"a", 4}, {"level_hint", 5}}
+	)
+
+	lg.SetFormat("%m")
+	lg.SetAppender(d)
+	lg.WithFields(fields...).Info("m")
+	assert.Equal("m a=4 b=2 level_hint=5 request_id=3 z=1\n", d.d)
+
+	SetFieldOrder([]string{"request_id", "level_hint"})
+	defer SetFieldOrder(nil)
+	lg.WithFields(fields...).Info("m")
+	assert.Equal("m request_id=3 level_hint=5 a=4 b=2 z=1\n", d.d)
+
+	lg.SetAppender(NewJSONAppender(buf))
+	lg.SetCallerMinLevel(FATAL)
+	lg.WithFields(fields...).Info("m")
+	assert.Contains(buf.String(), `"message":"m","request_id":3,"level_hint":5,"a":4,"b":2,"z":1}`)
+}
+
+func TestWithError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		buf    = bytes.NewBuffer(nil)
+		lg     = newTestLogger("witherror")
+		err    = fmt.Errorf("read: %w", &os.PathError{Op: "open", Path: "/x", Err: errors.New("denied")})
+	)
+
+	lg.SetFormat("[%l] %m")
+	lg.SetAppender(d)
+	lg.WithError(err).Error("failed")
+	assert.Equal("[ERROR] failed error=\"read: open /x: denied\"\n", d.d)
+	assert.True(lg == lg.WithError(nil))
+
+	ErrorTypeKey = "error_type"
+	defer func() { ErrorTypeKey = "" }()
+	lg.WithFields(Field{"k", 1}).WithError(err).Error("failed")
+	assert.Equal("[ERROR] failed error=\"read: open /x: denied\" error_type=*fmt.wrapError k=1\n", d.d)
+
+	lg.SetErrorChain(true)
+	lg.WithError(&opaqueError{"outer", errors.New("inner")}).Error("failed")
+	assert.Equal("[ERROR] failed error=\"outer: inner\" error_type=*log.opaqueError\n", d.d)
+
+	lg.SetAppender(NewJSONAppender(buf))
+	lg.SetCallerMinLevel(FATAL)
+	lg.WithError(err).Error("failed")
+	assert.Contains(buf.String(), `"message":"failed","error":"read: open /x: denied","error_type":"*fmt.wrapError"}`)
+
+	setGlobal(t, "%m", d)
+	ErrorTypeKey = ""
+	WithError(err).Error("failed")
+	assert.Equal("failed error=\"read: open /x: denied\"\n", d.d)
+}
+
+// nocallerap is the recordap which does not read the caller.
+type nocallerap struct {
+	recordap
+}
+
+func (a *nocallerap) NeedsCaller() bool { return false }
+
+func TestCallerAppender(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		r      = &recordap{}
+		n      = &nocallerap{}
+		lg     = newTestLogger("callerappender")
+	)
+
+	lg.SetFormat("[%l] %m")
+	lg.SetAppender(n)
+	lg.Info("a")
+	assert.Equal("[INFO] a\n", n.data)
+	assert.Empty(n.r.Caller)
+	assert.Zero(n.r.PC)
+
+	// resolved if any of the appenders needs it
+	lg.SetAppender(NewMultiAppender(n, r))
+	lg.Info("b")
+	assert.Equal("record_test.go", filepath.Base(n.r.Caller))
+	assert.Equal("record_test.go", filepath.Base(r.r.Caller))
+	lg.SetAppender(NewMultiAppender(n, NewCopyingAppender(n)))
+	lg.Info("c")
+	assert.Empty(n.r.Caller)
+
+	for app, want := range map[*HTTPAppender]bool{
+		NewLokiAppender("http://127.0.0.1:1", nil):                     false,
+		NewHTTPAppender("http://127.0.0.1:1", 1, 0):                    true,
+		NewHTTPAppender("http://127.0.0.1:1", 1, 0, HTTPCaller(false)): false,
+	} {
+		assert.Equal(want, needsCaller(app))
+		app.Close()
+	}
+	assert.False(needsCaller(NewShardedAppender("%s.log", nil)))
+}
+
+// fieldError dereferences its receiver, which panics for the nil pointer.
+type fieldError struct {
+	msg string
+}
+
+func (e *fieldError) Error() string { return e.msg }
+
+type panicStringer struct{}
+
+func (panicStringer) String() string { panic("boom") }
+
+func TestNilPointerFields(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("nilpointer")
+	)
+
+	lg.SetFormat("%m")
+	lg.SetAppender(d)
+	lg.WithFields(F("err", (*fieldError)(nil)), F("s", panicStringer{})).Info("x")
+	assert.Equal("x err=<nil> s=\"%!v(PANIC=String method: boom)\"\n", d.d)
+
+	lg.WithError((*fieldError)(nil)).Error("failed")
+	assert.Equal("failed error=<nil>\n", d.d)
+	lg.SetErrorChain(true)
+	lg.WithError(fmt.Errorf("wrap: %w", (*fieldError)(nil))).Error("failed")
+	assert.Equal("failed error=\"wrap: <nil>\"\n", d.d)
+}
+
+func TestKV(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("kv")
+	)
+
+	lg.SetLevel(TRACE)
+	lg.SetAppender(d)
+	lg.SetFormat("[%l] %c %m")
+	lg.InfoKV("done", F("took", time.Second), F("n", 2))
+	assert.Equal("[INFO] record_test.go done n=2 took=1s\n", d.d)
+	lg.TraceKV("100%")
+	assert.Equal("[TRACE] record_test.go 100%\n", d.d)
+
+	e := lg.WithFields(F("a", 1))
+	e.ErrorKV("failed", F("b", 2))
+	assert.Equal("[ERROR] record_test.go failed a=1 b=2\n", d.d)
+	e.WarnKV("m")
+	assert.Equal("[WARN] record_test.go m a=1\n", d.d)
+}
+
+func BenchmarkKV(b *testing.B) {
+	lg := newTestLogger("bench-kv")
+	lg.SetAppender(&null{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lg.InfoKV("BenchmarkKV running", F("name", "go go go"), F("n", 12345678))
+	}
+}
+
+func BenchmarkWithFields(b *testing.B) {
+	lg := newTestLogger("bench-withfields")
+	lg.SetAppender(&null{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lg.WithFields(F("name", "go go go"), F("n", 12345678)).Info("BenchmarkWithFields running")
+	}
+}
+
+func TestDurationAndTimeFields(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		buf    = bytes.NewBuffer(nil)
+		lg     = newTestLogger("durationfields")
+		tm     = time.Date(2021, 3, 4, 5, 6, 7, 8, time.UTC)
+		fields = []Field{Duration("took", 1500*time.Millisecond), Time("at", tm)}
+	)
+
+	lg.SetFormat("%m")
+	lg.SetAppender(d)
+	lg.SetCallerMinLevel(FATAL)
+	lg.InfoKV("m", fields...)
+	assert.Equal("m at=2021-03-04T05:06:07.000000008Z took=1.5s\n", d.d)
+	lg.SetAppender(NewJSONAppender(buf))
+	lg.InfoKV("m", fields...)
+	assert.Contains(buf.String(), `"message":"m","at":"2021-03-04T05:06:07.000000008Z","took":"1.5s"}`)
+
+	DurationNumeric = true
+	defer func() { DurationNumeric = false }()
+	buf.Reset()
+	lg.InfoKV("m", fields...)
+	assert.Contains(buf.String(), `"took":1500000000}`)
+	lg.SetAppender(d)
+	lg.InfoKV("m", fields...)
+	assert.Equal("m at=2021-03-04T05:06:07.000000008Z took=1500000000\n", d.d)
+}
+
+func TestSetStaticFields(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		r      = &recordap{}
+		lg0    = newTestLogger("static")
+		lg1    = newTestLogger("staticrecord")
+	)
+	defer SetStaticFields(nil)
+
+	lg0.SetFormat("[%l] %S %m")
+	lg0.SetAppender(d)
+	lg1.SetFormat("[%l] %m")
+	lg1.SetAppender(r)
+
+	lg0.Info("a")
+	assert.Equal("[INFO]  a\n", d.d)
+	SetStaticFields(map[string]string{"region": "us-east", "env": "prod", "zone": "a b"})
+	lg0.Info("a")
+	assert.Equal(`[INFO] env=prod region=us-east zone="a b" a`+"\n", d.d)
+	lg0.WithFields(Field{"k", 1}).Warn("b")
+	assert.Equal(`[WARN] env=prod region=us-east zone="a b" b k=1`+"\n", d.d)
+
+	lg1.Info("c")
+	assert.Equal(`[INFO] c env=prod region=us-east zone="a b"`+"\n", r.data)
+	lg1.WithFields(Field{"k", 1}).Warn("d")
+	assert.Equal(`[WARN] d env=prod k=1 region=us-east zone="a b"`+"\n", r.data)
+
+	// the static fields are rendered once, %S costs no allocation
+	lg0.SetFormat("[%l] %m")
+	base := testing.AllocsPerRun(100, func() { lg0.Info("a") })
+	lg0.SetFormat("[%l] %S %m")
+	assert.Equal(base, testing.AllocsPerRun(100, func() { lg0.Info("a") }))
+}
+
+func TestSampledFields(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		r      = &recordap{}
+		lg     = newTestLogger("sampled")
+	)
+
+	lg.SetFormat("[%l] %m")
+	lg.SetAppender(r)
+	lg.SetRatelimitSample(1, 5, INFO)
+	lg.WithFields(Field{"k", "v"}).Info("burst")
+	assert.Equal("[INFO] burst k=v\n", r.data)
+	for i := 0; i < 5; i++ {
+		lg.Infof("over %d", i)
+	}
+	// the 5th one over the limit is sampled after 4 dropped
+	assert.Equal("[INFO] over 4 dropped=4 sampled=true\n", r.data)
+
+	// the spare capacity of the fields of the entry is not overwritten
+	fields := append(make([]Field, 0, 2), Field{"k", "v"})
+	for i := 0; i < 5; i++ {
+		lg.WithFields(fields...).Info("again")
+	}
+	assert.Equal("[INFO] again dropped=4 k=v sampled=true\n", r.data)
+	assert.Equal(Field{}, fields[:2][1])
+}
@@ -0,0 +1,41 @@
+package log
+
+import "runtime/debug"
+
+// The Recover and RecoverAndContinue must call recover directly, since the
+// recover only stops the panicking when it is called by the deferred
+// function itself.
+
+func (l *logger) Recover() {
+	if r := recover(); r != nil {
+		l.recovered(&entry{logger: l, skip: 2}, r, true)
+	}
+}
+
+func (l *logger) RecoverAndContinue() {
+	if r := recover(); r != nil {
+		l.recovered(&entry{logger: l, skip: 2}, r, false)
+	}
+}
+
+func (e *entry) Recover() {
+	if r := recover(); r != nil {
+		e.recovered(&entry{logger: e.logger, fields: e.fields, skip: e.skip + 2}, r, true)
+	}
+}
+
+func (e *entry) RecoverAndContinue() {
+	if r := recover(); r != nil {
+		e.recovered(&entry{logger: e.logger, fields: e.fields, skip: e.skip + 2}, r, false)
+	}
+}
+
+// recovered logs the recovered panic r with the stack trace at ERROR, then
+// panics with r again if repanic. The skip of e locates the caller at the
+// panicking function.
+func (l *logger) recovered(e *entry, r interface{}, repanic bool) {
+	l.dolog(e, "panic: %v\n%s", ERROR, r, debug.Stack())
+	if repanic {
+		panic(r)
+	}
+}
@@ -0,0 +1,71 @@
+package log
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecover(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("recover")
+	)
+
+	lg.SetFormat("%c [%l] %m")
+	lg.SetAppender(d)
+
+	repanicked := func() (r interface{}) {
+		defer func() { r = recover() }()
+		func() {
+			defer lg.Recover()
+			panic("boom")
+		}()
+		return nil
+	}()
+	assert.Equal("boom", repanicked)
+	assert.True(strings.HasPrefix(d.d, "recover_test.go [ERROR] panic: boom\ngoroutine "), d.d)
+	assert.Contains(d.d, "TestRecover")
+
+	d.d = ""
+	func() {
+		defer lg.WithFields(Field{"job", 1}).RecoverAndContinue()
+		panic("swallowed")
+	}()
+	assert.True(strings.HasPrefix(d.d, "recover_test.go [ERROR] panic: swallowed\ngoroutine "), d.d)
+	assert.True(strings.HasSuffix(d.d, " job=1\n"), d.d)
+
+	d.d = ""
+	func() {
+		defer lg.RecoverAndContinue()
+	}()
+	assert.Equal("", d.d, "no panic")
+}
+
+func TestGlobalRecover(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+	)
+
+	setGlobal(t, "%c [%l] %m", d)
+
+	func() {
+		defer RecoverAndContinue()
+		panic("global")
+	}()
+	assert.True(strings.HasPrefix(d.d, "recover_test.go [ERROR] panic: global\n"), d.d)
+
+	repanicked := func() (r interface{}) {
+		defer func() { r = recover() }()
+		func() {
+			defer Default().Recover()
+			panic("default")
+		}()
+		return nil
+	}()
+	assert.Equal("default", repanicked)
+	assert.True(strings.HasPrefix(d.d, "recover_test.go [ERROR] panic: default\n"), d.d)
+}
@@ -0,0 +1,91 @@
+package log
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRetryMaxWait is the default max total time of the backoffs of
+// retrying a log by RetryAppender.
+const DefaultRetryMaxWait = time.Second
+
+// RetryAppender is an Appender which retries the failed output of the inner
+// appender with exponential backoff, the log is dropped and counted when
+// all the attempts fail, see Dropped. The inner appender reports the failure
+// by implementing TryAppender, otherwise the log is outputted once.
+//
+// The retries block the logging goroutine, so the total time of the backoffs
+// of a log is capped, see SetMaxWait.
+type RetryAppender struct {
+	dropped  uint64 // keep 64-bit aligned for atomic operations
+	inner    Appender
+	attempts int
+	backoff  time.Duration
+	maxwait  int64 // time.Duration
+}
+
+// NewRetryAppender returns a RetryAppender which outputs a log to inner at
+// most attempts times, the backoff before the first retry is backoff and it
+// is doubled for every retry.
+func NewRetryAppender(inner Appender, attempts int, backoff time.Duration) *RetryAppender {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return &RetryAppender{
+		inner:    inner,
+		attempts: attempts,
+		backoff:  backoff,
+		maxwait:  int64(DefaultRetryMaxWait),
+	}
+}
+
+// SetMaxWait set the max total time of the backoffs of retrying a log, the
+// log is dropped without more retries when the next backoff exceeds it.
+func (a *RetryAppender) SetMaxWait(d time.Duration) {
+	atomic.StoreInt64(&a.maxwait, int64(d))
+}
+
+func (a *RetryAppender) Output(level Level, t time.Time, data []byte) {
+	a.TryOutput(level, t, data)
+}
+
+// TryOutput outputs the data with the retries, it returns the error of the
+// last attempt when the log is dropped.
+func (a *RetryAppender) TryOutput(level Level, t time.Time, data []byte) error {
+	app, ok := a.inner.(TryAppender)
+	if !ok {
+		a.inner.Output(level, t, data)
+		return nil
+	}
+	var (
+		waited  time.Duration
+		backoff = a.backoff
+		maxwait = time.Duration(atomic.LoadInt64(&a.maxwait))
+		err     = app.TryOutput(level, t, data)
+	)
+	for i := 1; i < a.attempts && err != nil; i++ {
+		if waited+backoff > maxwait {
+			break
+		}
+		time.Sleep(backoff)
+		waited += backoff
+		backoff *= 2
+		err = app.TryOutput(level, t, data)
+	}
+	if err != nil {
+		atomic.AddUint64(&a.dropped, 1)
+	}
+	return err
+}
+
+func (a *RetryAppender) Flush() error {
+	if f, ok := a.inner.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Dropped returns the number of the logs dropped after the retries.
+func (a *RetryAppender) Dropped() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
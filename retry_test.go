@@ -0,0 +1,53 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyw is a writer which fails the first fails writes.
+type flakyw struct {
+	fails  int
+	writes int
+	buf    bytes.Buffer
+}
+
+func (w *flakyw) Write(p []byte) (int, error) {
+	if w.writes++; w.writes <= w.fails {
+		return 0, errors.New("transient failure")
+	}
+	return w.buf.Write(p)
+}
+
+func TestRetryAppender(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		w      = &flakyw{fails: 2}
+		a      = NewRetryAppender(NewWriterAppender(w), 3, time.Millisecond)
+		lg     = newTestLogger("retry")
+	)
+
+	lg.SetFormat("%m")
+	lg.SetAppender(a)
+	lg.Info("hello")
+	assert.Equal(3, w.writes)
+	assert.Equal("hello\n", w.buf.String())
+	assert.Equal(uint64(0), a.Dropped())
+
+	w.fails, w.writes = 10, 0
+	lg.Info("lost")
+	assert.Equal(3, w.writes)
+	assert.Equal("hello\n", w.buf.String())
+	assert.Equal(uint64(1), a.Dropped())
+
+	// the backoffs 1ms+2ms exceed the max wait, so only one retry.
+	w.writes = 0
+	a.SetMaxWait(2 * time.Millisecond)
+	assert.Error(a.TryOutput(INFO, time.Now(), []byte("lost\n")))
+	assert.Equal(2, w.writes)
+	assert.Equal(uint64(2), a.Dropped())
+}
@@ -0,0 +1,127 @@
+package log
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultShardMaxOpen is the default max number of the files opened by a
+// ShardedAppender.
+const DefaultShardMaxOpen = 64
+
+// ShardedAppender is a RecordAppender which routes every log to the file
+// of its shard, e.g. a file per tenant. Every shard is an hourly
+// RotateAppender, which is opened lazily and closed when it is the least
+// recently used shard and the number of the opened files exceeds the
+// limit.
+type ShardedAppender struct {
+	mu      sync.Mutex
+	pattern string
+	keyfn   func(Record) string
+	maxopen int
+	lru     *list.List // the front is the most recently used
+	shards  map[string]*list.Element
+}
+
+type shard struct {
+	key string
+	app *RotateAppender
+}
+
+// NewShardedAppender returns a ShardedAppender, the filename of a shard is
+// the pattern formatted with the key returned by keyFn like `fmt.Sprintf`,
+// e.g. "logs/tenant-%s.log". The path separators in the key are replaced
+// by '_'.
+func NewShardedAppender(pattern string, keyFn func(Record) string) *ShardedAppender {
+	return &ShardedAppender{
+		pattern: pattern,
+		keyfn:   keyFn,
+		maxopen: DefaultShardMaxOpen,
+		lru:     list.New(),
+		shards:  make(map[string]*list.Element),
+	}
+}
+
+// SetMaxOpen set the max number of the files opened at the same time.
+func (a *ShardedAppender) SetMaxOpen(n int) {
+	if n < 1 {
+		n = 1
+	}
+	a.mu.Lock()
+	a.maxopen = n
+	a.evict()
+	a.mu.Unlock()
+}
+
+func (a *ShardedAppender) Output(level Level, t time.Time, data []byte) {
+	a.OutputRecord(&Record{Level: level, Time: t, Data: data})
+}
+
+func (a *ShardedAppender) OutputRecord(r *Record) {
+	key := a.keyfn(*r)
+	a.mu.Lock()
+	if app := a.shard(key); app != nil {
+		app.Output(r.Level, r.Time, r.Data)
+	}
+	a.mu.Unlock()
+}
+
+// NeedsCaller is always false, the shards write the formatted data.
+func (a *ShardedAppender) NeedsCaller() bool {
+	return false
+}
+
+func (a *ShardedAppender) shard(key string) *RotateAppender {
+	if e, ok := a.shards[key]; ok {
+		a.lru.MoveToFront(e)
+		return e.Value.(*shard).app
+	}
+	filename := fmt.Sprintf(a.pattern, strings.NewReplacer("/", "_", "\\", "_").Replace(key))
+	app, err := NewHourlyRotateAppender(filename)
+	if err != nil {
+		println("sharded appender open ", filename, "error: ", err.Error())
+		return nil
+	}
+	a.shards[key] = a.lru.PushFront(&shard{key: key, app: app})
+	a.evict()
+	return app
+}
+
+func (a *ShardedAppender) evict() {
+	for a.lru.Len() > a.maxopen {
+		s := a.lru.Remove(a.lru.Back()).(*shard)
+		delete(a.shards, s.key)
+		s.app.Close()
+	}
+}
+
+// Flush flushes all the opened shards.
+func (a *ShardedAppender) Flush() error {
+	var err error
+	a.mu.Lock()
+	for e := a.lru.Front(); e != nil; e = e.Next() {
+		if e1 := e.Value.(*shard).app.Flush(); e1 != nil && err == nil {
+			err = e1
+		}
+	}
+	a.mu.Unlock()
+	return err
+}
+
+// Close closes all the opened shards.
+func (a *ShardedAppender) Close() error {
+	var err error
+	a.mu.Lock()
+	for e := a.lru.Front(); e != nil; e = e.Next() {
+		if e1 := e.Value.(*shard).app.Close(); e1 != nil && err == nil {
+			err = e1
+		}
+	}
+	a.lru.Init()
+	a.shards = make(map[string]*list.Element)
+	a.mu.Unlock()
+	return err
+}
@@ -0,0 +1,49 @@
+package log
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedAppender(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		dir    = t.TempDir()
+		lg     = newTestLogger("sharded")
+		app    = NewShardedAppender(filepath.Join(dir, "tenant-%s.log"), func(r Record) string {
+			for _, f := range r.Fields {
+				if f.Key == "tenant" {
+					return f.Value.(string)
+				}
+			}
+			return "default"
+		})
+	)
+	defer app.Close()
+
+	lg.SetAppender(app)
+	lg.SetFormat("%m")
+	app.SetMaxOpen(2)
+
+	lg.WithFields(Field{"tenant", "a"}).Info("1")
+	lg.WithFields(Field{"tenant", "b"}).Info("2")
+	lg.WithFields(Field{"tenant", "a"}).Info("3")
+	lg.WithFields(Field{"tenant", "../c"}).Info("4")
+	lg.Info("5")
+	assert.Equal(2, app.lru.Len())
+	assert.Nil(app.Flush())
+
+	for name, data := range map[string]string{
+		"tenant-a.log":       "1 tenant=a\n3 tenant=a\n",
+		"tenant-b.log":       "2 tenant=b\n",
+		"tenant-.._c.log":    "4 tenant=../c\n",
+		"tenant-default.log": "5\n",
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		assert.Nil(err)
+		assert.Equal(data, string(b), name)
+	}
+}
@@ -0,0 +1,87 @@
+package log
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// InstallSignalHandler cycles the log-level of global logger through the
+// given log-levels when receiving the signal, e.g. with the cycle
+// []Level{INFO, DEBUG, TRACE}, the log-level goes INFO->DEBUG->TRACE->INFO
+// on every `kill -HUP`. If the current log-level is not in the cycle, it
+// goes to the first one. The returned function stops the handler.
+func InstallSignalHandler(sig os.Signal, cycle []Level) (stop func()) {
+	var (
+		once sync.Once
+		ch   = make(chan os.Signal, 1)
+		done = make(chan struct{})
+	)
+
+	signal.Notify(ch, sig)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				if len(cycle) != 0 {
+					log.SetLevel(nextLevel(log.Level(), cycle))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+func nextLevel(level Level, cycle []Level) Level {
+	for i, l := range cycle {
+		if l == level {
+			return cycle[(i+1)%len(cycle)]
+		}
+	}
+	return cycle[0]
+}
+
+// InstallReopenHandler reopens the files of all the opened RotateAppenders
+// created with RotateReopenOnSignal when receiving the signal, which
+// cooperates with the external rotation tools like logrotate, which rename
+// the file and then send the signal, e.g. `postrotate kill -USR1 <pid>`.
+// The logs are written to the new file after the reopening. The returned
+// function stops the handler.
+func InstallReopenHandler(sig os.Signal) (stop func()) {
+	var (
+		once sync.Once
+		ch   = make(chan os.Signal, 1)
+		done = make(chan struct{})
+	)
+
+	signal.Notify(ch, sig)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				for _, a := range rotates.list() {
+					if err := a.Reopen(); err != nil {
+						println("appender reopen ", a.filename, "error: ", err.Error())
+					}
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+//go:build !windows
+// +build !windows
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstallSignalHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	defer SetLevel(log.Level())
+	SetLevel(WARN)
+	stop := InstallSignalHandler(syscall.SIGHUP, []Level{INFO, DEBUG, TRACE})
+	defer stop()
+
+	for _, expect := range []Level{INFO, DEBUG, TRACE, INFO} {
+		assert.Nil(syscall.Kill(syscall.Getpid(), syscall.SIGHUP))
+		assert.Eventually(func() bool { return log.Level() == expect },
+			time.Second, time.Millisecond, LevelsToString[expect])
+	}
+
+	stop()
+	stop()
+}
+
+func TestNextLevel(t *testing.T) {
+	assert := assert.New(t)
+	cycle := []Level{INFO, DEBUG}
+	assert.Equal(DEBUG, nextLevel(INFO, cycle))
+	assert.Equal(INFO, nextLevel(DEBUG, cycle))
+	assert.Equal(INFO, nextLevel(ERROR, cycle))
+}
+
+func TestInstallReopenHandler(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		dir      = t.TempDir()
+		filename = filepath.Join(dir, "a.log")
+	)
+
+	app, err := NewHourlyRotateBufAppender(filename, 4096, RotateReopenOnSignal())
+	if !assert.NoError(err) {
+		return
+	}
+	defer app.Close()
+	other, err := NewHourlyRotateAppender(filepath.Join(dir, "b.log"))
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal([]*RotateAppender{app}, rotates.list())
+	other.Close()
+	stop := InstallReopenHandler(syscall.SIGUSR1)
+	defer stop()
+
+	app.Output(INFO, time.Now(), []byte("before\n"))
+	assert.NoError(os.Rename(filename, filename+".1"))
+	assert.Nil(syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	assert.Eventually(func() bool {
+		_, err := os.Stat(filename)
+		return err == nil
+	}, time.Second, time.Millisecond)
+	app.Output(INFO, time.Now(), []byte("after\n"))
+	assert.NoError(app.Flush())
+
+	read := func(name string) string {
+		data, err := ioutil.ReadFile(name)
+		assert.NoError(err)
+		return string(data)
+	}
+	assert.Equal("before\n", read(filename+".1"))
+	assert.Equal("after\n", read(filename))
+}
@@ -0,0 +1,91 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package log
+
+import (
+	"log/syslog"
+	"sync"
+	"time"
+)
+
+// SyslogAppender is an Appender which writes the logs to the syslog daemon,
+// the log-levels are mapped to the syslog severities, FATAL to LOG_CRIT and
+// TRACE to LOG_DEBUG.
+//
+// The messages are sanitized by default, since the syslog daemons and the
+// relays may truncate the message at a NUL byte or split it at a newline.
+// The control bytes (0x00-0x1f and 0x7f) are replaced by '#' followed by
+// their 3 digits octal value like rsyslog does, e.g. a NUL byte becomes
+// "#000", a newline becomes "#012", and '#' itself is kept as is.
+type SyslogAppender struct {
+	mu       sync.Mutex
+	w        *syslog.Writer
+	sanitize bool
+}
+
+// NewSyslogAppender returns a SyslogAppender which connects to the syslog
+// daemon like `syslog.Dial`, the facility is LOG_USER.
+func NewSyslogAppender(network, raddr, tag string) (*SyslogAppender, error) {
+	w, err := syslog.Dial(network, raddr, syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogAppender{w: w, sanitize: true}, nil
+}
+
+// SetSanitize set whether or not to replace the control bytes of the
+// messages, it is enabled by default.
+func (a *SyslogAppender) SetSanitize(enable bool) {
+	a.mu.Lock()
+	a.sanitize = enable
+	a.mu.Unlock()
+}
+
+func (a *SyslogAppender) Output(level Level, t time.Time, data []byte) {
+	if n := len(data); n > 0 && data[n-1] == '\n' {
+		data = data[:n-1]
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var msg string
+	if a.sanitize {
+		b := sanitizeSyslog(getbuf(), data)
+		msg = string(b)
+		putbuf(b)
+	} else {
+		msg = string(data)
+	}
+	switch level {
+	case FATAL:
+		a.w.Crit(msg)
+	case ERROR:
+		a.w.Err(msg)
+	case WARN:
+		a.w.Warning(msg)
+	case INFO:
+		a.w.Info(msg)
+	default:
+		a.w.Debug(msg)
+	}
+}
+
+// Close closes the connection to the syslog daemon.
+func (a *SyslogAppender) Close() error {
+	return a.w.Close()
+}
+
+// sanitizeSyslog appends data to b with the control bytes replaced by their
+// "#ooo" octal escapes.
+func sanitizeSyslog(b, data []byte) []byte {
+	start := 0
+	for i, c := range data {
+		if c >= 0x20 && c != 0x7f {
+			continue
+		}
+		b = append(b, data[start:i]...)
+		b = append(b, '#', '0'+c>>6, '0'+c>>3&7, '0'+c&7)
+		start = i + 1
+	}
+	return append(b, data[start:]...)
+}
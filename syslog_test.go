@@ -0,0 +1,60 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package log
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeSyslog(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("plain # text", string(sanitizeSyslog(nil, []byte("plain # text"))))
+	assert.Equal("a#000b", string(sanitizeSyslog(nil, []byte("a\x00b"))))
+	assert.Equal("#011tab#012line#015#177", string(sanitizeSyslog(nil, []byte("\ttab\nline\r\x7f"))))
+	assert.Equal("中文#033", string(sanitizeSyslog(nil, []byte("中文\x1b"))))
+}
+
+func TestSyslogAppender(t *testing.T) {
+	assert := assert.New(t)
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !assert.NoError(err) {
+		return
+	}
+	defer conn.Close()
+
+	a, err := NewSyslogAppender("udp", conn.LocalAddr().String(), "logtest")
+	if !assert.NoError(err) {
+		return
+	}
+	defer a.Close()
+
+	read := func() string {
+		buf := make([]byte, 1024)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		assert.NoError(err)
+		return string(buf[:n])
+	}
+
+	lg := newTestLogger("syslog")
+	lg.SetFormat("%m")
+	lg.SetAppender(a)
+	lg.Error("nul\x00byte")
+	msg := read()
+	assert.True(strings.HasPrefix(msg, "<11>"), msg) // LOG_USER|LOG_ERR
+	assert.True(strings.HasSuffix(msg, "logtest["+strconv.Itoa(os.Getpid())+"]: nul#000byte\n"), msg)
+
+	a.SetSanitize(false)
+	lg.Info("raw\tmessage")
+	msg = read()
+	assert.True(strings.HasPrefix(msg, "<14>"), msg) // LOG_USER|LOG_INFO
+	assert.True(strings.HasSuffix(msg, ": raw\tmessage\n"), msg)
+}
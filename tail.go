@@ -0,0 +1,86 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+)
+
+// TailFunc returns the last n lines of the file without the newlines, the
+// whole file is returned if it has less than n lines. The file is read
+// backward by chunks, so it is cheap to tail a large file. The buffered logs
+// of the RotateAppender writing the file are not flushed, see
+// RotateAppender.Tail.
+func TailFunc(filename string, n int) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return tail(f, n)
+}
+
+// TailFile is the same as TailFunc.
+func TailFile(filename string, n int) ([]string, error) {
+	return TailFunc(filename, n)
+}
+
+// Tail flushes the buffered logs, then returns the last n lines of the
+// current file of the appender, see TailFunc.
+func (a *RotateAppender) Tail(n int) ([]string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if bw, ok := a.w.(Flusher); ok {
+		if err := bw.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	return TailFunc(a.filename, n)
+}
+
+// tailChunk is the size of the chunk read backward, it is a variable for the
+// tests.
+var tailChunk = 4096
+
+func tail(f *os.File, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	end, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		off  = end
+		data []byte
+		nl   int
+	)
+	// read until there are n newlines ahead of the last byte or the start
+	// of the file, the last byte is the newline ending the last line.
+	for off > 0 && nl < n {
+		size := int64(tailChunk)
+		if off < size {
+			size = off
+		}
+		off -= size
+		chunk := make([]byte, size, int64(len(data))+size)
+		if _, err := f.ReadAt(chunk, off); err != nil {
+			return nil, err
+		}
+		if len(data) == 0 {
+			nl += bytes.Count(chunk[:size-1], []byte{'\n'})
+		} else {
+			nl += bytes.Count(chunk, []byte{'\n'})
+		}
+		data = append(chunk, data...)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
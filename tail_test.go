@@ -0,0 +1,62 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTail(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		filename = filepath.Join(t.TempDir(), "tail.log")
+	)
+
+	_, err := TailFunc(filename, 1)
+	assert.True(os.IsNotExist(err))
+
+	app, err := NewHourlyRotateBufAppender(filename, 4096)
+	if !assert.NoError(err) {
+		return
+	}
+	defer app.Close()
+
+	lines, err := app.Tail(3)
+	assert.NoError(err)
+	assert.Empty(lines)
+
+	lg := newTestLogger("tail")
+	lg.SetFormat("%m")
+	lg.SetAppender(app)
+	lg.Info("line 0")
+	lg.Info("line 1")
+	lines, err = app.Tail(3) // flushes the buffered logs
+	assert.NoError(err)
+	assert.Equal([]string{"line 0", "line 1"}, lines)
+
+	defer func(n int) { tailChunk = n }(tailChunk)
+	tailChunk = 7 // spans lines across chunks
+	for i := 2; i < 100; i++ {
+		lg.Infof("line %d", i)
+	}
+	for _, n := range []int{0, 1, 2, 10, 99, 100, 1000} {
+		lines, err = app.Tail(n)
+		assert.NoError(err)
+		var expect []string
+		for i := 100 - n; i < 100; i++ {
+			if i >= 0 {
+				expect = append(expect, fmt.Sprintf("line %d", i))
+			}
+		}
+		assert.Equal(expect, lines, "%d", n)
+		lines, err = TailFunc(filename, n)
+		assert.NoError(err)
+		assert.Equal(expect, lines, "%d", n)
+		lines, err = TailFile(filename, n)
+		assert.NoError(err)
+		assert.Equal(expect, lines, "%d", n)
+	}
+}
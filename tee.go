@@ -0,0 +1,83 @@
+package log
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// TeeWriter is an io.Writer which writes to all of its writers like
+// io.MultiWriter, but it keeps writing to the others when some of them fail,
+// e.g. driven by the AIO to write to both the file and the console:
+//
+//	NewAIO(NewTeeWriter(file, os.Stdout), 4096)
+//
+// The AIO is not faulted by the failure of some of the writers, it is only
+// faulted when all of them fail.
+type TeeWriter struct {
+	writers []io.Writer
+	fault   atomic.Value
+}
+
+// TeeError is the error of the TeeWriter, Errs is the errors of its writers
+// in order, which is nil for the writers succeeded.
+type TeeError struct {
+	Errs []error
+}
+
+func (e *TeeError) Error() string {
+	var b strings.Builder
+	b.WriteString("log: tee writer")
+	for i, err := range e.Errs {
+		if err != nil {
+			b.WriteString(" #")
+			b.WriteString(strconv.Itoa(i))
+			b.WriteString(": ")
+			b.WriteString(err.Error())
+		}
+	}
+	return b.String()
+}
+
+// NewTeeWriter returns a TeeWriter which writes to the writers in order.
+func NewTeeWriter(writers ...io.Writer) *TeeWriter {
+	return &TeeWriter{writers: append([]io.Writer(nil), writers...)}
+}
+
+// Write writes p to all the writers. It returns len(p) with a *TeeError if
+// some of them fail, and 0 with the *TeeError if all of them fail.
+func (t *TeeWriter) Write(p []byte) (int, error) {
+	var (
+		errs   []error
+		failed int
+	)
+	for i, w := range t.writers {
+		n, err := w.Write(p)
+		if err == nil && n < len(p) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			if errs == nil {
+				errs = make([]error, len(t.writers))
+			}
+			errs[i] = err
+			failed++
+		}
+	}
+	if failed == 0 {
+		return len(p), nil
+	}
+	err := &TeeError{Errs: errs}
+	t.fault.Store(struct{ error }{err})
+	if failed == len(t.writers) {
+		return 0, err
+	}
+	return len(p), err
+}
+
+// Err returns the last error of the writing.
+func (t *TeeWriter) Err() error {
+	err, _ := t.fault.Load().(struct{ error })
+	return err.error
+}
@@ -0,0 +1,55 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTeeWriter(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		w0     = bytes.NewBuffer(nil)
+		w1     = bytes.NewBuffer(nil)
+		tee    = NewTeeWriter(w0, &faultbuf{}, w1)
+	)
+
+	n, err := tee.Write([]byte("a"))
+	assert.Equal(1, n)
+	var te *TeeError
+	if assert.True(errors.As(err, &te)) {
+		assert.Equal([]error{nil, io.ErrClosedPipe, nil}, te.Errs)
+	}
+	assert.Equal("log: tee writer #1: io: read/write on closed pipe", err.Error())
+	assert.Equal(err, tee.Err())
+	assert.Equal("a", w0.String())
+	assert.Equal("a", w1.String())
+
+	n, err = NewTeeWriter(&faultbuf{}, &faultbuf{}).Write([]byte("a"))
+	assert.Equal(0, n)
+	assert.Error(err)
+}
+
+func TestAIOTeeWriter(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		w0     = bytes.NewBuffer(nil)
+		aio    = NewAIO(NewTeeWriter(&faultbuf{}, w0), 4)
+	)
+
+	// the AIO keeps working when some of the writers fail
+	aio.Write([]byte("abcdef"))
+	assert.NoError(aio.Flush())
+	aio.Write([]byte("gh"))
+	assert.NoError(aio.Close())
+	assert.Equal("abcdefgh", w0.String())
+
+	// and is faulted when all of them fail
+	aio = NewAIO(NewTeeWriter(&faultbuf{}), 4)
+	aio.Write([]byte("ab"))
+	assert.Error(aio.Flush())
+	assert.Error(aio.Close())
+}
@@ -0,0 +1,142 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package log
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// UnixSocketBufferSize is the max bytes of the logs buffered by the
+	// UnixSocketAppender during the outage of the socket, the oldest logs
+	// are dropped when it is exceeded.
+	UnixSocketBufferSize = 1 << 20
+	// UnixSocketRetryInterval is the min interval of the reconnecting of the
+	// UnixSocketAppender.
+	UnixSocketRetryInterval = 100 * time.Millisecond
+	// UnixSocketWriteTimeout is the timeout of writing to the socket, the
+	// connection is closed and reconnected after it.
+	UnixSocketWriteTimeout = time.Second
+)
+
+// UnixSocketAppender is an appender which writes the logs to a unix domain
+// stream socket, e.g. of the log collector running as a sidecar on the same
+// host. The connection is reconnected when it fails, the logs are buffered
+// in the outage up to UnixSocketBufferSize and written after reconnecting.
+// The log partially written to the failed connection is written again as a
+// whole after reconnecting.
+type UnixSocketAppender struct {
+	dropped uint64 // keep 64-bit aligned for atomic operations
+	mu      sync.Mutex
+	path    string
+	conn    net.Conn
+	pending [][]byte // the logs not written yet
+	size    int      // the bytes of pending
+	retried time.Time
+	closed  bool
+}
+
+// NewUnixSocketAppender returns a UnixSocketAppender which is connected to
+// the socket of the path.
+func NewUnixSocketAppender(path string) (*UnixSocketAppender, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &UnixSocketAppender{path: path, conn: conn}, nil
+}
+
+func (a *UnixSocketAppender) Output(level Level, t time.Time, data []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		atomic.AddUint64(&a.dropped, 1)
+		return
+	}
+	a.pending = append(a.pending, append([]byte(nil), data...))
+	a.size += len(data)
+	for a.size > UnixSocketBufferSize && len(a.pending) > 1 {
+		a.size -= len(a.pending[0])
+		a.pending[0] = nil
+		a.pending = a.pending[1:]
+		atomic.AddUint64(&a.dropped, 1)
+	}
+	a.write(false)
+}
+
+// write writes the pending logs, it reconnects the socket if it is not
+// connected and the last reconnecting is older than UnixSocketRetryInterval
+// or force.
+func (a *UnixSocketAppender) write(force bool) error {
+	if a.conn == nil {
+		if !force && time.Since(a.retried) < UnixSocketRetryInterval {
+			return errors.New("log: unix socket " + a.path + " is not connected")
+		}
+		a.retried = time.Now()
+		conn, err := net.Dial("unix", a.path)
+		if err != nil {
+			return err
+		}
+		a.conn = conn
+	}
+	for len(a.pending) != 0 {
+		data := a.pending[0]
+		a.conn.SetWriteDeadline(time.Now().Add(UnixSocketWriteTimeout))
+		if _, err := a.conn.Write(data); err != nil {
+			// the partially written log is lost with the connection, the
+			// whole of it is written again after reconnecting, so that the
+			// peer never receives the tail of a log without its head.
+			a.conn.Close()
+			a.conn = nil
+			return err
+		}
+		a.size -= len(data)
+		a.pending[0] = nil
+		a.pending = a.pending[1:]
+	}
+	a.pending = nil
+	return nil
+}
+
+// Flush writes the buffered logs, it reconnects the socket immediately if it
+// is not connected.
+func (a *UnixSocketAppender) Flush() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return errors.New("log: unix socket " + a.path + " is closed")
+	}
+	return a.write(true)
+}
+
+// Close writes the buffered logs and closes the connection, the subsequent
+// logs are dropped.
+func (a *UnixSocketAppender) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	err := a.write(true)
+	atomic.AddUint64(&a.dropped, uint64(len(a.pending)))
+	a.pending, a.size = nil, 0
+	if a.conn != nil {
+		if e := a.conn.Close(); err == nil {
+			err = e
+		}
+		a.conn = nil
+	}
+	return err
+}
+
+// Dropped returns the number of the logs dropped by the overflow of the
+// buffer or the closing.
+func (a *UnixSocketAppender) Dropped() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
@@ -0,0 +1,159 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package log
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// unixserver accepts the connections of the socket and sends the lines read
+// from them to lines.
+func unixserver(t *testing.T, path string, lines chan<- string) (net.Listener, chan net.Conn) {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+			go func() {
+				s := bufio.NewScanner(conn)
+				for s.Scan() {
+					lines <- s.Text()
+				}
+			}()
+		}
+	}()
+	return l, conns
+}
+
+func TestUnixSocketAppender(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		path   = filepath.Join(t.TempDir(), "log.sock")
+		lines  = make(chan string, 16)
+	)
+
+	recv := func() string {
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(time.Second):
+			return "timeout"
+		}
+	}
+
+	l, conns := unixserver(t, path, lines)
+	app, err := NewUnixSocketAppender(path)
+	if !assert.NoError(err) {
+		return
+	}
+	app.Output(INFO, time.Now(), []byte("a\n"))
+	assert.Equal("a", recv())
+
+	// the logs are buffered in the outage
+	l.Close()
+	(<-conns).Close()
+	for i := 0; i < 3; i++ {
+		app.Output(INFO, time.Now(), []byte("b\n"))
+	}
+	assert.Error(app.Flush())
+
+	l, _ = unixserver(t, path, lines)
+	defer l.Close()
+	assert.NoError(app.Flush(), "reconnected")
+	app.Output(INFO, time.Now(), []byte("c\n"))
+	assert.NoError(app.Close())
+	for _, line := range []string{"b", "b", "b", "c"} {
+		assert.Equal(line, recv())
+	}
+	assert.Zero(app.Dropped())
+	app.Output(INFO, time.Now(), []byte("d\n"))
+	assert.EqualValues(1, app.Dropped())
+
+	_, err = NewUnixSocketAppender(filepath.Join(t.TempDir(), "none.sock"))
+	assert.Error(err)
+}
+
+// partialconn writes the half of the data and fails.
+type partialconn struct {
+	net.Conn
+	written []byte
+}
+
+func (c *partialconn) Write(b []byte) (int, error) {
+	c.written = append(c.written, b[:len(b)/2]...)
+	return len(b) / 2, errors.New("broken pipe")
+}
+
+func (c *partialconn) SetWriteDeadline(t time.Time) error { return nil }
+func (c *partialconn) Close() error                       { return nil }
+
+func TestUnixSocketAppenderPartialWrite(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		path   = filepath.Join(t.TempDir(), "log.sock")
+		lines  = make(chan string, 16)
+		broken = &partialconn{}
+	)
+	l, _ := unixserver(t, path, lines)
+	defer l.Close()
+	app, err := NewUnixSocketAppender(path)
+	if !assert.NoError(err) {
+		return
+	}
+	app.conn.Close()
+	app.conn = broken
+	app.Output(INFO, time.Now(), []byte("hello\n"))
+	assert.Equal("hel", string(broken.written))
+	assert.Equal(6, app.size)
+
+	// the whole log is written after reconnecting
+	assert.NoError(app.Flush())
+	assert.NoError(app.Close())
+	select {
+	case line := <-lines:
+		assert.Equal("hello", line)
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+	assert.Zero(app.Dropped())
+}
+
+func TestUnixSocketAppenderOverflow(t *testing.T) {
+	defer func(n int) { UnixSocketBufferSize = n }(UnixSocketBufferSize)
+	UnixSocketBufferSize = 4
+
+	var (
+		assert = assert.New(t)
+		path   = filepath.Join(t.TempDir(), "log.sock")
+		lines  = make(chan string, 16)
+	)
+	l, _ := unixserver(t, path, lines)
+	app, err := NewUnixSocketAppender(path)
+	if !assert.NoError(err) {
+		return
+	}
+	l.Close()
+	app.conn.Close() // the outage
+	for _, data := range []string{"a\n", "b\n", "c\n"} {
+		app.Output(INFO, time.Now(), []byte(data))
+	}
+	assert.EqualValues(1, app.Dropped(), "the oldest is dropped")
+	assert.Equal([][]byte{[]byte("b\n"), []byte("c\n")}, app.pending)
+	app.Close()
+	assert.EqualValues(3, app.Dropped())
+}
@@ -0,0 +1,55 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	stdlog "log"
+)
+
+// writer is an io.Writer which emits a log at its level for every line
+// written to it.
+type writer struct {
+	l     *logger
+	e     *entry
+	level Level
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		line := p
+		if i := bytes.IndexByte(p, '\n'); i >= 0 {
+			line, p = p[:i], p[i+1:]
+		} else {
+			p = nil
+		}
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		if len(line) != 0 {
+			w.l.dolog(w.e, "%s", w.level, line)
+		}
+	}
+	return n, nil
+}
+
+// stdlibskip is the number of the frames of the logger of standard library
+// between its caller and the writer, i.e. (*Logger).Printf and the
+// (*Logger).output, which are skipped to resolve the caller.
+const stdlibskip = 2
+
+func (l *logger) StdlibAdapter(level Level) *stdlog.Logger {
+	return stdlog.New(&writer{l: l, e: &entry{logger: l, skip: stdlibskip}, level: level}, "", 0)
+}
+
+func (e *entry) StdlibAdapter(level Level) *stdlog.Logger {
+	ee := *e
+	ee.skip += stdlibskip
+	return stdlog.New(&writer{l: e.logger, e: &ee, level: level}, "", 0)
+}
+
+func (l *logger) LevelWriter(level Level) io.Writer {
+	return &writer{l: l, level: level}
+}
+
+func (e *entry) LevelWriter(level Level) io.Writer {
+	return &writer{l: e.logger, e: e, level: level}
+}
@@ -0,0 +1,109 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	stdlog "log"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type linesap struct {
+	levels []Level
+	lines  []string
+}
+
+func (a *linesap) Output(level Level, t time.Time, data []byte) {
+	a.levels = append(a.levels, level)
+	a.lines = append(a.lines, string(data))
+}
+
+func TestStdlibAdapter(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		a      = &linesap{}
+		lg     = newTestLogger("stdlib")
+	)
+
+	lg.SetAppender(a)
+	lg.SetFormat("[%l] %m")
+
+	std := lg.StdlibAdapter(WARN)
+	std.Printf("hello %s", "world")
+	std.Print("multi\nline\r\n\n")
+	lg.WithFields(Field{"k", "v"}).StdlibAdapter(ERROR).Println("fields")
+
+	assert.Equal([]Level{WARN, WARN, WARN, ERROR}, a.levels)
+	assert.Equal([]string{
+		"[WARN] hello world\n",
+		"[WARN] multi\n",
+		"[WARN] line\n",
+		"[ERROR] fields k=v\n",
+	}, a.lines)
+}
+
+func TestStdlibAdapterCaller(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("stdlibcaller")
+	)
+
+	lg.SetFormat("%c:%L %m")
+	lg.SetAppender(d)
+	setGlobal(t, "%c:%L %m", d)
+	_, _, line, _ := runtime.Caller(0)
+	want := fmt.Sprintf("writer_test.go:%d x\n", line+3)
+	for _, std := range []*stdlog.Logger{lg.StdlibAdapter(INFO), lg.WithFields().StdlibAdapter(INFO), StdlibAdapter(INFO)} {
+		std.Print("x")
+		assert.Equal(want, d.d)
+	}
+}
+
+func TestLevelWriter(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		a      = &linesap{}
+		lg     = newTestLogger("levelwriter")
+	)
+
+	lg.SetAppender(a)
+	lg.SetFormat("[%l] %m")
+
+	access, errw := lg.LevelWriter(INFO), lg.LevelWriter(ERROR)
+	n, err := access.Write([]byte("GET /a 200\nGET /b 200\n"))
+	assert.Equal(22, n)
+	assert.NoError(err)
+	errw.Write([]byte("panic: x\r\n\ngoroutine 1"))
+	fmt.Fprintf(lg.WithFields(Field{"k", "v"}).LevelWriter(WARN), "slow %dms\n", 100)
+
+	assert.Equal([]Level{INFO, INFO, ERROR, ERROR, WARN}, a.levels)
+	assert.Equal([]string{
+		"[INFO] GET /a 200\n",
+		"[INFO] GET /b 200\n",
+		"[ERROR] panic: x\n",
+		"[ERROR] goroutine 1\n",
+		"[WARN] slow 100ms k=v\n",
+	}, a.lines)
+}
+
+func TestLevelWriterCaller(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		d      = &dap{}
+		lg     = newTestLogger("levelwritercaller")
+	)
+
+	lg.SetFormat("%c:%L %m")
+	lg.SetAppender(d)
+	setGlobal(t, "%c:%L %m", d)
+	_, _, line, _ := runtime.Caller(0)
+	want := fmt.Sprintf("writer_test.go:%d x\n", line+3)
+	for _, w := range []io.Writer{lg.LevelWriter(INFO), lg.WithFields().LevelWriter(INFO), LevelWriter(INFO)} {
+		w.Write([]byte("x"))
+		assert.Equal(want, d.d)
+	}
+}